@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/mehix/gopher-burrows/internal/burrows"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportPath        string
+	exportInputFormat string
+	exportServer      string
+	exportFormat      string
+	exportSelector    string
+	exportOutput      string
+	exportTact        time.Duration
+)
+
+var cmdExport = &cobra.Command{
+	Use:   "export",
+	Short: "Export the burrow inventory as CSV, JSON, NDJSON or text",
+	Long: `Write every burrow together with its status, free slots, volume, tenant and remaining lifetime.
+The burrows are read from a dump or data file (--path) or from a running server (--server).
+When reading a file, the collapse times are computed from the time the file was written and --tact.`,
+	Example: `  burrows export --path dumps/dump_20240101T000000.000000000Z.json --format csv -o inventory.csv
+  burrows export --server http://127.0.0.1:8080 --selector site=north`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := burrows.ParseExportFormat(exportFormat)
+		if err != nil {
+			return err
+		}
+
+		export := exportFromFile
+		if exportServer != "" {
+			export = exportFromServer
+		}
+
+		if exportOutput == "" {
+			return export(cmd.OutOrStdout(), format)
+		}
+		f, err := os.Create(exportOutput)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if err := export(f, format); err != nil {
+			return err
+		}
+		return f.Close()
+	},
+}
+
+func init() {
+	cmdExport.Flags().StringVar(&exportPath, "path", "", "dump or data file to export")
+	cmdExport.Flags().StringVar(&exportInputFormat, "input-format", "", "format of the file: json, yaml, ndjson or csv (default: from the extension)")
+	cmdExport.Flags().StringVar(&exportServer, "server", "", "address of a running server to export from, instead of a file")
+	cmdExport.Flags().StringVar(&exportFormat, "format", "text", "output format: json, ndjson, csv or text")
+	cmdExport.Flags().StringVar(&exportSelector, "selector", "", "only export the burrows with matching labels, e.g. site=north")
+	cmdExport.Flags().StringVarP(&exportOutput, "output", "o", "", "write to this file instead of stdout")
+	cmdExport.Flags().DurationVar(&exportTact, "tact", time.Minute, "how long one minute in the life of a burrow lasts, only used for files")
+	cmdExport.MarkFlagsMutuallyExclusive("path", "server")
+	cmdExport.MarkFlagsOneRequired("path", "server")
+	cmdExport.SilenceUsage = true
+}
+
+func exportFromFile(w io.Writer, format burrows.Format) error {
+	sel, err := burrows.ParseSelector(exportSelector)
+	if err != nil {
+		return err
+	}
+
+	inFormat := burrows.FormatOf(exportPath)
+	if exportInputFormat != "" {
+		if inFormat, err = burrows.ParseFormat(exportInputFormat); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.Open(exportPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec, err := burrows.NewDecoder(f, inFormat)
+	if err != nil {
+		return err
+	}
+	now := dec.Taken()
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	exp, err := burrows.NewExporter(w, format)
+	if err != nil {
+		return err
+	}
+	for {
+		b, err := dec.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if !sel.Matches(b.Labels) {
+			continue
+		}
+		if err := exp.Write(burrows.NewExportRecord(b, now, exportTact)); err != nil {
+			return err
+		}
+	}
+	return exp.Flush()
+}
+
+func exportFromServer(w io.Writer, format burrows.Format) error {
+	u, err := url.JoinPath(exportServer, "/export")
+	if err != nil {
+		return err
+	}
+	query := url.Values{"format": {string(format)}}
+	if exportSelector != "" {
+		query.Set("selector", exportSelector)
+	}
+
+	resp, err := http.Get(u + "?" + query.Encode())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s: %s", resp.Status, msg)
+	}
+
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mehix/gopher-burrows/internal/burrows"
+	"github.com/spf13/pflag"
+)
+
+// daysValue is a duration flag that also accepts days, ex: `30d`
+type daysValue time.Duration
+
+func (d *daysValue) Set(s string) error {
+	v, err := burrows.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = daysValue(v)
+	return nil
+}
+
+func (d *daysValue) Type() string { return "duration" }
+
+func (d *daysValue) String() string { return time.Duration(*d).String() }
+
+// daysVar defines a duration flag that accepts days on top of the usual units
+func daysVar(fs *pflag.FlagSet, p *time.Duration, name string, value time.Duration, usage string) {
+	*p = value
+	fs.Var((*daysValue)(p), name, usage)
+}
+
+// sizeValue is a number of bytes with an optional unit, ex: `500MB` or `2G`. Units are powers of 1024
+type sizeValue int64
+
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30}, {"TB", 1 << 40},
+	{"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30}, {"T", 1 << 40},
+	{"B", 1},
+}
+
+func (v *sizeValue) Set(s string) error {
+	n, unit := strings.ToUpper(strings.TrimSpace(s)), int64(1)
+	for _, u := range sizeUnits {
+		if rest, ok := strings.CutSuffix(n, u.suffix); ok {
+			n, unit = strings.TrimSpace(rest), u.bytes
+			break
+		}
+	}
+	size, err := strconv.ParseInt(n, 10, 64)
+	if err != nil || size < 0 {
+		return fmt.Errorf("invalid size %q, ex: 500MB", s)
+	}
+	*v = sizeValue(size * unit)
+	return nil
+}
+
+func (v *sizeValue) Type() string { return "size" }
+
+func (v *sizeValue) String() string { return strconv.FormatInt(int64(*v), 10) }
+
+// sizeVar defines a flag for a number of bytes that accepts units
+func sizeVar(fs *pflag.FlagSet, p *int64, name string, value int64, usage string) {
+	*p = value
+	fs.Var((*sizeValue)(p), name, usage)
+}
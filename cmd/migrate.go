@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mehix/gopher-burrows/internal/burrows"
+	"github.com/spf13/cobra"
+)
+
+var migrateInPlace bool
+
+var cmdMigrate = &cobra.Command{
+	Use:   "migrate FILE...",
+	Short: "Convert data files and dumps to the current schema version",
+	Long: fmt.Sprintf(`Read data files or dumps of any schema version and write them in the current version (%d).
+A single file is written to stdout, unless --in-place is set. The server migrates older files
+when it loads them, this command converts them offline.`, burrows.SchemaVersion),
+	Example: "  burrows migrate data/initial.json > initial.v3.json\n  burrows migrate --in-place dumps/*.json",
+	Args:    cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) > 1 && !migrateInPlace {
+			return fmt.Errorf("migrating %d files requires --in-place", len(args))
+		}
+
+		for _, path := range args {
+			snapshot, err := readSnapshotFile(path)
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+
+			from := snapshot.Metadata.MigratedFrom
+			snapshot.Metadata = burrows.Metadata{}
+			if !migrateInPlace {
+				return burrows.WriteSnapshot(cmd.OutOrStdout(), snapshot)
+			}
+
+			if from == 0 {
+				fmt.Fprintf(cmd.ErrOrStderr(), "%s: already at version %d\n", path, burrows.SchemaVersion)
+				continue
+			}
+			if err := burrows.WriteSnapshotFile(path, snapshot); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "%s: migrated from version %d to %d\n", path, from, burrows.SchemaVersion)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	cmdMigrate.Flags().BoolVar(&migrateInPlace, "in-place", false, "replace the files with their migrated version")
+}
+
+func readSnapshotFile(path string) (burrows.Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return burrows.Snapshot{}, err
+	}
+	defer f.Close()
+
+	return burrows.ReadSnapshot(f)
+}
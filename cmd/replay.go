@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mehix/gopher-burrows/internal/burrows"
+	"github.com/spf13/cobra"
+)
+
+var (
+	replayLog   string
+	replayAging bool
+	replayDump  string
+)
+
+var cmdReplay = &cobra.Command{
+	Use:   "replay",
+	Short: "Print the timeline of an event log and the burrows it rebuilds",
+	Long: `Read the event log of a server and print every state transition of the burrows in order,
+followed by a report of the burrows as they were when the server stopped.
+A log that was truncated when a dump was written starts from that dump, it has to be found at the path the server wrote it to.
+The aging of the burrows and the checkpoints of the dumps are left out of the timeline unless --aging is set.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		f, err := os.Open(replayLog)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		events, err := burrows.ReadEvents(f)
+		if err != nil {
+			return err
+		}
+
+		out := cmd.OutOrStdout()
+		if err := writeTimeline(out, events, replayAging); err != nil {
+			return err
+		}
+
+		rebuilt, err := burrows.Replay(events, burrows.Dump.Read)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "\n%d events, %d burrows\n", len(events), len(rebuilt))
+		tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		if err := burrows.NewReport(rebuilt).Write(tw); err != nil {
+			return err
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+
+		if replayDump == "" {
+			return nil
+		}
+		snapshot := burrows.Snapshot{Burrows: rebuilt}
+		if len(events) > 0 {
+			snapshot.Taken = events[len(events)-1].At
+		}
+		dump, err := os.Create(replayDump)
+		if err != nil {
+			return err
+		}
+		defer dump.Close()
+		if err := burrows.WriteSnapshot(dump, snapshot); err != nil {
+			return err
+		}
+		return dump.Close()
+	},
+}
+
+func init() {
+	cmdReplay.Flags().StringVar(&replayLog, "log", "events.log", "event log to replay")
+	cmdReplay.Flags().BoolVar(&replayAging, "aging", false, "also print when the burrows aged and the checkpoints of the dumps")
+	cmdReplay.Flags().StringVar(&replayDump, "dump", "", "write the rebuilt burrows to this file, it can be loaded with serve --path")
+}
+
+func writeTimeline(w io.Writer, events []burrows.Event, aging bool) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SEQ\tTIME\tEVENT\tSHARD\tBURROW\tDETAILS\t")
+
+	for _, e := range events {
+		if (e.Type == burrows.EventAged || e.Type == burrows.EventCheckpoint) && !aging {
+			continue
+		}
+
+		name, details := "", ""
+		if e.Burrow != nil {
+			name = e.Burrow.Name
+		}
+		switch e.Type {
+		case burrows.EventAdded:
+			details = fmt.Sprintf("capacity %d, age %s", e.Burrow.Slots(), formatElapsed(time.Duration(e.Burrow.AgeInMin)*time.Minute))
+		case burrows.EventRented, burrows.EventVacated, burrows.EventRemoved:
+			details = strings.Join(e.Gophers, ", ")
+		case burrows.EventUpdated:
+			details = fmt.Sprintf("capacity %d, width %.3f", e.Burrow.Slots(), e.Burrow.Width)
+		case burrows.EventLabeled:
+			var labels []string
+			for k, v := range e.Burrow.Labels {
+				labels = append(labels, k+"="+v)
+			}
+			sort.Strings(labels)
+			details = strings.Join(labels, ",")
+		case burrows.EventAged:
+			details = fmt.Sprintf("%d min", e.Minutes*max(e.Ticks, 1))
+		case burrows.EventSnapshot:
+			name, details = e.Dump, fmt.Sprintf("%d burrows", sum(e.Sizes))
+		case burrows.EventCollapsed:
+			details = fmt.Sprintf("depth %.3f", e.Burrow.Depth)
+		case burrows.EventAborted:
+			details = "the previous change was not saved"
+		}
+
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%s\t%s\t\n", e.Seq, e.At.Format(time.RFC3339), e.Type, e.Shard, name, details)
+	}
+
+	return tw.Flush()
+}
+
+func sum(values []int) int {
+	total := 0
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mehix/gopher-burrows/internal/burrows"
+	"github.com/spf13/cobra"
+)
+
+var (
+	diffFormat      string
+	diffInputFormat string
+	diffSelector    string
+)
+
+var cmdReport = &cobra.Command{
+	Use:   "report",
+	Short: "Work with the report files and dumps",
+}
+
+var cmdReportDiff = &cobra.Command{
+	Use:   "diff FROM TO",
+	Short: "Compare the burrows of two reports or dumps",
+	Long: `Show how the burrows changed from one file to the other: the burrows added, removed, newly occupied,
+vacated and collapsed, and the change of the total depth, the availability and the other metrics.
+The files are JSON report files, gzipped or not, or dumps and data files. Reports don't list their burrows,
+so comparing a report only compares the metrics.`,
+	Example: `  burrows report diff dumps/dump_20240301T000000.000000000Z.json dumps/dump_20240308T000000.000000000Z.json
+  burrows report diff reports/burrows_20240301_000000.json.gz reports/burrows_20240308_000000.json --format json`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if diffFormat != "text" && diffFormat != "json" {
+			return fmt.Errorf("unknown diff format: %s", diffFormat)
+		}
+		sel, err := burrows.ParseSelector(diffSelector)
+		if err != nil {
+			return err
+		}
+
+		var reports [2]burrows.Report
+		for i, path := range args {
+			if reports[i], err = readDiffReport(path, sel); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+		}
+
+		diff := burrows.DiffReports(reports[0], reports[1])
+		if diffFormat == "json" {
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			return enc.Encode(diff)
+		}
+		return diff.Write(cmd.OutOrStdout())
+	},
+}
+
+func init() {
+	cmdReportDiff.Flags().StringVar(&diffFormat, "format", "text", "output format: text or json")
+	cmdReportDiff.Flags().StringVar(&diffInputFormat, "input-format", "", "format of dumps and data files: json, yaml, ndjson or csv (default: from the extension)")
+	cmdReportDiff.Flags().StringVar(&diffSelector, "selector", "", "only compare the burrows with matching labels, e.g. site=north. not available for reports")
+	cmdReportDiff.SilenceUsage = true
+	cmdReport.AddCommand(cmdReportDiff)
+}
+
+// readDiffReport reads a report file or summarizes a dump or data file. Gzipped files are uncompressed
+func readDiffReport(path string, sel burrows.Selector) (burrows.Report, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return burrows.Report{}, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	name := path
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return burrows.Report{}, err
+		}
+		defer gz.Close()
+		r, name = gz, strings.TrimSuffix(path, ".gz")
+	}
+
+	format := burrows.FormatOf(name)
+	if diffInputFormat != "" {
+		if format, err = burrows.ParseFormat(diffInputFormat); err != nil {
+			return burrows.Report{}, err
+		}
+	}
+	return burrows.ReadDiffReport(r, format, sel)
+}
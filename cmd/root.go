@@ -1,6 +1,9 @@
 package cmd
 
-import "github.com/spf13/cobra"
+import (
+	"github.com/mehix/gopher-burrows/internal/burrows"
+	"github.com/spf13/cobra"
+)
 
 var cmdRoot = &cobra.Command{
 	Use:   "burrows",
@@ -9,6 +12,7 @@ var cmdRoot = &cobra.Command{
 }
 
 func Execute() error {
-	cmdRoot.AddCommand(cmdServe, cmdVersion)
+	burrows.Generator = "burrows " + version
+	cmdRoot.AddCommand(cmdServe, cmdSimulate, cmdReplay, cmdMigrate, cmdExport, cmdReport, cmdSpeed, cmdVersion)
 	return cmdRoot.Execute()
 }
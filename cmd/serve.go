@@ -1,18 +1,17 @@
 package cmd
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"log/slog"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
-	"path/filepath"
-	"text/tabwriter"
+	"strings"
 	"time"
 
 	"github.com/mehix/gopher-burrows/internal/burrows"
@@ -25,8 +24,26 @@ var (
 	addr          string
 	fPath         string
 	verbose       bool
-	reportingDir  string
 	reportingFreq time.Duration
+	reportingSel  string
+	reportingFmts []string
+	reportingHist string
+	reportingTmpl string
+	schedulesPath string
+	reportFiles   burrows.ReportFiles
+	tact          time.Duration
+	catchUp       bool
+	storeKind     string
+	storePath     string
+	dumpDir       string
+	dumpEvery     time.Duration
+	dumpKeep      int
+	dumpMaxAge    time.Duration
+	restore       bool
+	eventLogPath  string
+	dataFormat    string
+	watchEvery    time.Duration
+	watchDir      string
 )
 
 var cmdServe = &cobra.Command{
@@ -45,28 +62,151 @@ var cmdServe = &cobra.Command{
 
 		errs := make(chan error, 1)
 
+		reportsScope, err := burrows.ParseSelector(reportingSel)
+		if err != nil {
+			logger.Error("invalid reports selector", "error", err.Error())
+			return
+		}
+
+		if reportingTmpl != "" {
+			tmpl, err := burrows.NewReportTemplate(logger, reportingTmpl)
+			if err != nil {
+				logger.Error("invalid report template", "path", reportingTmpl, "error", err.Error())
+				return
+			}
+			burrows.RegisterReportEncoder("template", tmpl)
+			// the template replaces the default text layout
+			if !cmd.Flags().Changed("repos-format") {
+				reportingFmts = []string{"template"}
+			}
+		}
+
+		schedules, err := readReportSchedules(reportsScope)
+		if err != nil {
+			logger.Error("invalid report schedules", "path", schedulesPath, "error", err.Error())
+			return
+		}
+		sinks, err := openReportSinks(schedules)
+		if err != nil {
+			logger.Error("reports can not be delivered", "error", err.Error())
+			return
+		}
+
+		history, err := openReportHistory(reportingHist)
+		if err != nil {
+			logger.Error("report history not opened", "path", reportingHist, "error", err.Error())
+			return
+		}
+		if history != nil {
+			defer history.Close()
+		}
+
+		if watchDir != "" && watchEvery <= 0 {
+			logger.Error("--watch-dir needs a --watch interval")
+			return
+		}
+
+		if err := checkDir(dumpDir); err != nil {
+			logger.Error("dumps can not be written", "dir", dumpDir, "error", err.Error())
+			return
+		}
+
+		store, err := openStore(storeKind, storePath)
+		if err != nil {
+			logger.Error("store not opened", "store", storeKind, "path", storePath, "error", err.Error())
+			return
+		}
+		defer store.Close()
+
+		stored, err := store.Load()
+		if err != nil {
+			logger.Error("burrows not loaded from the store", "store", storeKind, "path", storePath, "error", err.Error())
+			return
+		}
+
+		logged, err := readEventLog(eventLogPath)
+		if err != nil {
+			logger.Error("event log not read", "path", eventLogPath, "error", err.Error())
+			return
+		}
+
+		source, closeSource, err := openInitialData(stored, logged)
+		if err != nil {
+			logger.Error("initial data not loaded", "error", err.Error())
+			return
+		}
+		defer closeSource()
+
+		downtime := 0
+		if catchUp {
+			downtime = burrows.CatchUpMinutes(source.Taken(), time.Now(), tact)
+			logger.Info("burrows catch up with the downtime", "taken", source.Taken(), "minutes", downtime)
+		}
+
+		events, err := openEventLog(eventLogPath)
+		if err != nil {
+			logger.Error("event log not opened", "path", eventLogPath, "error", err.Error())
+			return
+		}
+		defer events.Close()
+
 		// Create manager and load data
-		manager := burrows.NewManager(ctx, logger)
+		manager := burrows.NewManager(ctx, logger,
+			burrows.WithTact(tact),
+			burrows.WithStore(store),
+			burrows.WithEventLog(events),
+			burrows.WithDumpDir(dumpDir),
+			burrows.WithSnapshots(dumpEvery),
+			burrows.WithRetention(dumpKeep, dumpMaxAge),
+		)
 
 		burrowsStream := make(chan burrows.Burrow)
+		streamed := make(chan error, 1)
+
+		go func() {
+			streamed <- streamBurrows(ctx, source, downtime, burrowsStream)
+		}()
 
 		go func() {
 			manager.Load(burrowsStream)
+			if err := <-streamed; err != nil {
+				errs <- fmt.Errorf("initial data not loaded: %w", err)
+				return
+			}
+
+			// the new log holds all the burrows now, it replaces the old one
+			if l, ok := events.(*burrows.FileEventLog); ok {
+				if err := l.Rename(eventLogPath); err != nil {
+					logger.Error("event log not replaced", "path", eventLogPath, "error", err.Error())
+				}
+			}
 
 			logger.Debug("manager data loaded", "data", manager.CurrentStatus())
-		}()
 
-		go loadInitialData(ctx, burrowsStream, errs)
+			// the watchers only start now, or they would add the burrows that are still being loaded
+			if watchEvery > 0 {
+				watchData(ctx, manager)
+			}
+		}()
 
-		go generatePeriodicReports(ctx, manager, errs)
+		scheduler := burrows.NewScheduler(logger, burrows.RealClock, writeScheduledReport(manager, sinks, history), schedules...)
+		go scheduler.Run(ctx, burrows.RealClock.NewTicker(time.Second))
 
 		// Create the HTTP server
+		handlerOpts := []bhttp.HandlerOption{bhttp.WithSchedules(scheduler.Schedules)}
+		if history != nil {
+			handlerOpts = append(handlerOpts, bhttp.WithReportHistory(history))
+		}
+		if dumpDir != "" {
+			handlerOpts = append(handlerOpts, bhttp.WithDumps(burrows.Dumps{Dir: dumpDir}))
+		}
+		handler := bhttp.Handler(manager, handlerOpts...)
 		srvr := &http.Server{
 			Addr:         addr,
 			BaseContext:  func(_ net.Listener) context.Context { return ctx },
 			ReadTimeout:  time.Second,
 			WriteTimeout: 10 * time.Second,
-			Handler:      bhttp.Handler(manager),
+			Handler:      handler,
 		}
 
 		go func() {
@@ -76,12 +216,13 @@ var cmdServe = &cobra.Command{
 
 		select {
 		case err := <-errs:
-			if err != nil && errors.Is(err, http.ErrServerClosed) {
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
 				logger.Error(err.Error())
 			}
 		case <-ctx.Done():
-			stop()
 		}
+		// the manager only stops, and writes its dump, once the context is done
+		stop()
 
 		_ = srvr.Shutdown(context.Background())
 
@@ -91,65 +232,332 @@ var cmdServe = &cobra.Command{
 
 func init() {
 	cmdServe.Flags().StringVar(&addr, "addr", "127.0.0.1:8080", "HTTP address to listen on")
-	cmdServe.Flags().StringVar(&fPath, "path", "data/initial.json", "Load initial burrows data")
+	cmdServe.Flags().StringVar(&fPath, "path", "data/initial.json", "Load initial burrows data. ignored if the store, the event log or --restore have burrows")
+	cmdServe.Flags().StringVar(&dataFormat, "format", "", "format of --path: json, yaml, ndjson or csv. detected from the extension by default")
 	cmdServe.Flags().BoolVarP(&verbose, "verbose", "v", false, "enable more verbose logging")
 
-	cmdServe.Flags().StringVar(&reportingDir, "repos-dir", "/tmp", "path to write out reports")
+	cmdServe.Flags().StringVar(&reportFiles.Dir, "repos-dir", "/tmp", "path to write out reports")
 	cmdServe.Flags().DurationVar(&reportingFreq, "repos-freq", 10*time.Minute, "frequency for writing out reports")
+	cmdServe.Flags().StringSliceVar(&reportingFmts, "repos-format", []string{"text"}, "formats of the reports, one file per format: "+strings.Join(burrows.ReportFormats(), ", "))
+	cmdServe.Flags().IntVar(&reportFiles.Keep, "repos-keep", 0, "number of reports to keep in --repos-dir. 0 keeps all of them")
+	daysVar(cmdServe.Flags(), &reportFiles.MaxAge, "repos-max-age", 0, "remove the reports that are older, ex: 7d. 0 keeps them forever")
+	sizeVar(cmdServe.Flags(), &reportFiles.MaxSize, "repos-max-size", 0, "remove the oldest files of --repos-dir until they fit, ex: 500MB. 0 is no limit")
+	daysVar(cmdServe.Flags(), &reportFiles.CompressAfter, "repos-compress-after", 0, "gzip the reports that are older, ex: 1h. 0 never compresses them")
+	cmdServe.Flags().BoolVar(&reportFiles.Rollup, "repos-rollup", false, "also append every report to a daily rollup, burrows_YYYYMMDD.ndjson")
+	cmdServe.Flags().StringVar(&reportingHist, "repos-history", "", "also append every report to this file, queried with GET /reports. empty disables the history")
+	cmdServe.Flags().StringVar(&reportingTmpl, "repos-template", "", "render the reports with this text/template or html/template file, the report format named template. reloaded when it changes")
+	cmdServe.Flags().StringVar(&schedulesPath, "repos-schedules", "", "YAML file of named report schedules with cron expressions. replaces --repos-freq, --repos-format and --repos-selector")
+	cmdServe.Flags().StringVar(&reportingSel, "repos-selector", "", "only report on the burrows matching this label selector, ex: site=north")
+
+	cmdServe.Flags().DurationVarP(&tact, "tact", "t", time.Minute, "change the speed with which the data is generated")
+	cmdServe.Flags().BoolVar(&catchUp, "catch-up", false, "when loading a dump, the store or the event log, age the burrows by the time the server was down")
 
-	cmdServe.Flags().DurationVarP(&burrows.Tact, "tact", "t", time.Minute, "change the speed with which the data is generated")
+	cmdServe.Flags().StringVar(&dumpDir, "dump-dir", ".", "directory of the dump files. an empty value disables the dumps")
+	cmdServe.Flags().DurationVar(&dumpEvery, "dump-every", 0, "write a dump file at this interval, not only on shutdown. 0 disables the periodic dumps")
+	cmdServe.Flags().IntVar(&dumpKeep, "dump-keep", 0, "number of dump files to keep. 0 keeps all of them")
+	daysVar(cmdServe.Flags(), &dumpMaxAge, "dump-max-age", 0, "remove the dump files that are older, ex: 7d. 0 keeps them forever")
+	cmdServe.Flags().BoolVar(&restore, "restore", false, "start from the newest valid dump in --dump-dir, fall back to --path if there is none. ignored if the store or the event log have burrows")
+
+	cmdServe.Flags().DurationVar(&watchEvery, "watch", 0, "poll --path at this interval and reconcile the burrows with its changes. 0 disables the watching")
+	cmdServe.Flags().StringVar(&watchDir, "watch-dir", "", "also reconcile the burrows with the data files dropped into this directory, polled at the --watch interval")
+
+	cmdServe.Flags().StringVar(&eventLogPath, "event-log", "", "append every change of the burrows to this log and replay it on startup. ignored on startup if the store has burrows")
+
+	cmdServe.Flags().StringVar(&storeKind, "store", "none", "persist every change of the burrows: none, json or bolt")
+	cmdServe.Flags().StringVar(&storePath, "store-path", "burrows.db", "file of the store. if it has burrows, they are loaded instead of the event log, --restore and --path")
 }
 
-func loadInitialData(ctx context.Context, burrowsStream chan<- burrows.Burrow, errs chan<- error) {
-	defer close(burrowsStream)
-	b, err := os.ReadFile(fPath)
+// openInitialData picks the most recent data to start from: the store, the event log,
+// the newest valid dump with `--restore` and finally the data file.
+// The returned function releases the data file.
+func openInitialData(stored burrows.Snapshot, logged []burrows.Event) (burrows.Decoder, func(), error) {
+	nop := func() {}
+
+	if len(stored.Burrows) > 0 {
+		// the burrows that did not change before the last save still have the age they had when they were saved
+		stored.Align(tact)
+		logger.Info("resume from the store", "store", storeKind, "path", storePath, "taken", stored.Taken, "burrows", len(stored.Burrows))
+		return burrows.NewSnapshotDecoder(stored), nop, nil
+	}
+
+	if len(logged) > 0 {
+		rebuilt, err := burrows.Replay(logged, burrows.Dump.Read)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", eventLogPath, err)
+		}
+		snapshot := burrows.Snapshot{Taken: logged[len(logged)-1].At, Burrows: rebuilt}
+		logger.Info("replay the event log", "path", eventLogPath, "events", len(logged), "burrows", len(snapshot.Burrows))
+		return burrows.NewSnapshotDecoder(snapshot), nop, nil
+	}
+
+	if restore {
+		dump, snapshot, err := burrows.Dumps{Dir: dumpDir}.Latest(func(d burrows.Dump, err error) {
+			logger.Warn("corrupt dump skipped", "path", d.Path, "error", err.Error())
+		})
+		if err == nil {
+			logger.Info("restore from dump", "path", dump.Path, "taken", snapshot.Taken, "burrows", len(snapshot.Burrows))
+			return burrows.NewSnapshotDecoder(snapshot), nop, nil
+		}
+		logger.Warn("nothing to restore, load the data file", "dir", dumpDir, "path", fPath, "error", err.Error())
+	}
+
+	format := burrows.FormatOf(fPath)
+	if dataFormat != "" {
+		var err error
+		if format, err = burrows.ParseFormat(dataFormat); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	f, err := os.Open(fPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	dec, err := burrows.NewDecoder(f, format)
 	if err != nil {
-		errs <- err
-		return
+		f.Close()
+		return nil, nil, fmt.Errorf("%s: %w", fPath, err)
 	}
+	logger.Info("load the data file", "path", fPath, "format", format)
 
-	var data []burrows.Burrow
-	if err := json.NewDecoder(bytes.NewReader(b)).Decode(&data); err != nil {
-		errs <- err
-		return
+	return dec, func() { f.Close() }, nil
+}
+
+// watchData reconciles the manager with the changes of the data file and with the files of the drop-in directory.
+// The data file was just loaded, or the burrows were restored from a newer state, so only its later changes count.
+// The files of the drop-in directory are all reconciled once, as some may have been dropped while the server was down.
+func watchData(ctx context.Context, manager burrows.Manager) {
+	var format burrows.Format
+	if dataFormat != "" {
+		var err error
+		if format, err = burrows.ParseFormat(dataFormat); err != nil {
+			logger.Error("data file not watched", "path", fPath, "error", err.Error())
+			return
+		}
 	}
 
-	for _, b := range data {
+	w := burrows.NewWatcher(logger, manager, fPath, format)
+	if err := w.Skip(); err != nil {
+		logger.Warn("data file not watched", "path", fPath, "error", err.Error())
+	} else {
+		logger.Info("watch the data file", "path", fPath, "every", watchEvery)
+		go w.Run(ctx, burrows.RealClock.NewTicker(watchEvery))
+	}
+
+	if watchDir != "" {
+		logger.Info("watch the drop-in directory", "dir", watchDir, "every", watchEvery)
+		go burrows.NewWatcher(logger, manager, watchDir, "").Run(ctx, burrows.RealClock.NewTicker(watchEvery))
+	}
+}
+
+// streamBurrows sends the burrows to the manager, aged by the downtime, and closes the stream.
+// Burrows are decoded while they are sent, large data files never need to fit into memory.
+func streamBurrows(ctx context.Context, source burrows.Decoder, downtime int, burrowsStream chan<- burrows.Burrow) error {
+	defer close(burrowsStream)
+	for {
+		b, err := source.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		b.AgeBy(downtime)
+
 		select {
 		case <-ctx.Done():
-			return
+			return ctx.Err()
 		case burrowsStream <- b:
 		}
 	}
 }
 
-func generatePeriodicReports(ctx context.Context, manager burrows.Manager, errs chan<- error) {
+// checkDir creates the directory of the dumps or reports and makes sure it is writable,
+// so that a read-only directory is noticed on startup and not when the first file is due.
+func checkDir(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0o775); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, ".write-check-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// readEventLog returns the events of the log of the previous run, if any
+func readEventLog(path string) ([]burrows.Event, error) {
+	if path == "" {
+		return nil, nil
+	}
 
-	tkr := time.NewTicker(reportingFreq)
-	defer tkr.Stop()
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
 
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-tkr.C:
-			fpath := filepath.Join(reportingDir, fmt.Sprintf("%s_%s.txt", "burrows", time.Now().Format("20060102_150405")))
-			report := manager.Report()
+	return burrows.ReadEvents(f)
+}
+
+// openEventLog starts a new log next to the old one. The new log replaces the old one
+// once it holds all the burrows, so a crash while loading does not lose the old log.
+func openEventLog(path string) (burrows.EventLog, error) {
+	if path == "" {
+		return burrows.NopEventLog, nil
+	}
+
+	if err := os.Remove(path + ".new"); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return burrows.NewFileEventLog(path + ".new")
+}
+
+func openStore(kind, path string) (burrows.Store, error) {
+	switch kind {
+	case "none":
+		return burrows.NopStore, nil
+	case "json":
+		return burrows.NewJSONStore(path)
+	case "bolt":
+		return burrows.NewBoltStore(path)
+	default:
+		return nil, fmt.Errorf("unknown store: %s", kind)
+	}
+}
+
+// readReportSchedules reads the schedules of `--repos-schedules`. Without it, a single schedule named `default`
+// writes a report every `--repos-freq` in the formats of `--repos-format`.
+// Schedules without a directory write to `--repos-dir`.
+func readReportSchedules(scope burrows.Selector) ([]burrows.ReportSchedule, error) {
+	if schedulesPath == "" {
+		if _, err := lookupReportEncoders(reportingFmts); err != nil {
+			return nil, err
+		}
+		cron, err := burrows.ParseCron("@every " + burrows.FormatDuration(reportingFreq))
+		if err != nil {
+			return nil, err
+		}
+		return []burrows.ReportSchedule{{
+			Name:     "default",
+			Cron:     cron,
+			Formats:  reportingFmts,
+			Dir:      reportFiles.Dir,
+			Selector: scope,
+			History:  true,
+		}}, nil
+	}
+
+	f, err := os.Open(schedulesPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	schedules, err := burrows.ReadReportSchedules(f)
+	if err != nil {
+		return nil, err
+	}
+	for i := range schedules {
+		if schedules[i].Dir == "" {
+			schedules[i].Dir = reportFiles.Dir
+		}
+	}
+	return schedules, nil
+}
+
+// openReportSinks creates the sinks of every schedule, by the name of the schedule.
+// Schedules without sinks write files to their directory, like file sinks without a directory.
+// The files of the schedules of `--repos-schedules` are named after their schedule, since they may share a directory.
+func openReportSinks(schedules []burrows.ReportSchedule) (map[string][]burrows.ReportSink, error) {
+	sinks := make(map[string][]burrows.ReportSink)
+	for i := range schedules {
+		s := &schedules[i]
+		files := reportFiles
+		if schedulesPath != "" {
+			files.Schedule = s.Name
+		}
+		if len(s.Sinks) == 0 {
+			s.Sinks = []burrows.SinkConfig{{Type: burrows.SinkFile}}
+		}
+		for j := range s.Sinks {
+			cfg := &s.Sinks[j]
+			if cfg.Type == burrows.SinkFile && cfg.Dir == "" {
+				cfg.Dir = s.Dir
+			}
+			if cfg.Type == burrows.SinkFile {
+				if err := checkDir(cfg.Dir); err != nil {
+					return nil, fmt.Errorf("schedule %s: %w", s.Name, err)
+				}
+			}
 
-			f, err := os.OpenFile(fpath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0664)
+			sink, err := burrows.NewReportSink(logger, *cfg, files)
 			if err != nil {
-				errs <- err
-				return
+				return nil, fmt.Errorf("schedule %s: %w", s.Name, err)
 			}
-			defer f.Close()
+			sinks[s.Name] = append(sinks[s.Name], sink)
+		}
+	}
+	return sinks, nil
+}
+
+// writeScheduledReport delivers the report of a schedule to all its sinks and adds it to the history.
+// A sink that fails does not keep the report from the others
+func writeScheduledReport(manager burrows.Manager, sinks map[string][]burrows.ReportSink, history burrows.ReportHistory) func(ctx context.Context, s burrows.ReportSchedule, at time.Time) error {
+	return func(ctx context.Context, s burrows.ReportSchedule, _ time.Time) error {
+		encoders, err := lookupReportEncoders(s.Formats)
+		if err != nil {
+			return err
+		}
+		report := manager.Report(s.Selector)
 
-			w := tabwriter.NewWriter(f, 15, 0, 0, ' ', tabwriter.AlignRight)
-			defer w.Flush()
+		var errs []error
+		for i, sink := range sinks[s.Name] {
+			if err := sink.Send(ctx, report, encoders); err != nil {
+				errs = append(errs, fmt.Errorf("%s sink: %w", s.Sinks[i].Type, err))
+			}
+		}
 
-			if err := report.Write(f); err == nil {
-				logger.Info("report generated", "filename", fpath)
+		if s.History && history != nil {
+			if err := history.Append(report); err != nil {
+				errs = append(errs, fmt.Errorf("report not added to the history: %w", err))
 			}
+		}
+		return errors.Join(errs...)
+	}
+}
+
+// openReportHistory returns nil when the history is disabled
+func openReportHistory(path string) (burrows.ReportHistory, error) {
+	if path == "" {
+		return nil, nil
+	}
+	return burrows.NewFileReportHistory(path)
+}
+
+// lookupReportEncoders returns the encoders of the report formats. The same format is only written once
+func lookupReportEncoders(formats []string) ([]burrows.ReportEncoder, error) {
+	var encoders []burrows.ReportEncoder
+	seen := make(map[string]bool)
+	for _, f := range formats {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if seen[f] {
+			continue
+		}
+		seen[f] = true
 
+		enc, err := burrows.LookupReportEncoder(f)
+		if err != nil {
+			return nil, err
 		}
+		encoders = append(encoders, enc)
 	}
+	return encoders, nil
 }
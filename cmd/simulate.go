@@ -0,0 +1,382 @@
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mehix/gopher-burrows/internal/burrows"
+	"github.com/spf13/cobra"
+)
+
+var (
+	simPath      string
+	simDuration  time.Duration
+	simStep      time.Duration
+	simModel     string
+	simRate      float64
+	simFamilyMax int
+	simSeed      int64
+	simFormat    string
+	simInput     string
+	simStay      time.Duration
+)
+
+var cmdSimulate = &cobra.Command{
+	Use:   "simulate",
+	Short: "Forecast the burrows without running a server",
+	Long: `Run the lifecycle of the burrows from a data file or a dump faster than real time.
+Rentals arrive according to the chosen model and the gophers leave after a random stay.
+The output is a time series of the availability, the collapsed burrows and the total depth.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		arrivals, err := newArrivalModel(simModel, simRate)
+		if err != nil {
+			return err
+		}
+		if simFamilyMax < 1 {
+			return fmt.Errorf("invalid family size: %d", simFamilyMax)
+		}
+		// the burrows age by whole minutes, the points of the time series fall on them
+		if simStep < time.Minute || simStep%time.Minute != 0 {
+			return fmt.Errorf("the step should be a whole number of minutes, got: %v", simStep)
+		}
+		if simDuration < 0 || simDuration%time.Minute != 0 {
+			return fmt.Errorf("the duration should be a whole number of minutes, got: %v", simDuration)
+		}
+		if simStay < 0 {
+			return fmt.Errorf("invalid stay: %v", simStay)
+		}
+
+		snapshot, err := readSimulationInput(simPath)
+		if err != nil {
+			return err
+		}
+
+		out, err := newSeriesWriter(cmd.OutOrStdout(), simFormat)
+		if err != nil {
+			return err
+		}
+
+		return simulate(snapshot, arrivals, out)
+	},
+}
+
+func init() {
+	cmdSimulate.Flags().StringVar(&simPath, "path", "data/initial.json", "data file or dump to start from")
+	daysVar(cmdSimulate.Flags(), &simDuration, "duration", 30*24*time.Hour, "how far into the future to simulate, ex: 30d")
+	daysVar(cmdSimulate.Flags(), &simStep, "step", 24*time.Hour, "time between two points of the time series, a whole number of minutes. the last point is always at the end of the duration")
+	cmdSimulate.Flags().StringVar(&simModel, "model", "poisson", "rental arrival model: poisson or constant")
+	cmdSimulate.Flags().Float64Var(&simRate, "rate", 2, "average number of rentals per day")
+	cmdSimulate.Flags().IntVar(&simFamilyMax, "family-max", 1, "size of the largest family. family sizes are uniformly distributed between 1 and this value")
+	cmdSimulate.Flags().Int64Var(&simSeed, "seed", 1, "seed for the random rentals, the same seed gives the same forecast")
+	cmdSimulate.Flags().StringVar(&simFormat, "format", "text", "output format: text or csv")
+	cmdSimulate.Flags().StringVar(&simInput, "input-format", "", "format of the data file or dump: json, yaml, ndjson or csv (default: from the extension)")
+	daysVar(cmdSimulate.Flags(), &simStay, "stay", 14*24*time.Hour, "average time the gophers stay in their burrow, ex: 14d. the stays are exponentially distributed. 0 means nobody leaves")
+	cmdSimulate.Flags().BoolVarP(&verbose, "verbose", "v", false, "log the lifecycle of the burrows to stderr")
+}
+
+// readSimulationInput reads the burrows of a data file or a dump, in any format of the data files
+func readSimulationInput(path string) (burrows.Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return burrows.Snapshot{}, err
+	}
+	defer f.Close()
+
+	format := burrows.FormatOf(path)
+	if simInput != "" {
+		if format, err = burrows.ParseFormat(simInput); err != nil {
+			return burrows.Snapshot{}, err
+		}
+	}
+	dec, err := burrows.NewDecoder(f, format)
+	if err != nil {
+		return burrows.Snapshot{}, err
+	}
+
+	var snapshot burrows.Snapshot
+	for {
+		b, err := dec.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return burrows.Snapshot{}, err
+		}
+		snapshot.Burrows = append(snapshot.Burrows, b)
+	}
+	snapshot.Taken = dec.Taken()
+	return snapshot, nil
+}
+
+// stayModel returns how long gophers stay in their burrow, at least a minute. Zero is forever
+type stayModel func(rnd *rand.Rand) time.Duration
+
+func newStayModel(mean time.Duration) stayModel {
+	if mean == 0 {
+		return func(_ *rand.Rand) time.Duration { return 0 }
+	}
+	return func(rnd *rand.Rand) time.Duration {
+		minutes := math.Ceil(rnd.ExpFloat64() * mean.Minutes())
+		return time.Duration(max(minutes, 1)) * time.Minute
+	}
+}
+
+// arrivalModel returns how many rentals arrive during one minute of simulated time
+type arrivalModel func(rnd *rand.Rand) int
+
+func newArrivalModel(name string, perDay float64) (arrivalModel, error) {
+	if perDay < 0 {
+		return nil, fmt.Errorf("invalid rental rate: %v", perDay)
+	}
+	perMinute := perDay / (24 * 60)
+
+	switch name {
+	case "poisson":
+		// Knuth's algorithm, good enough for the small rates of a single minute
+		limit := math.Exp(-perMinute)
+		return func(rnd *rand.Rand) int {
+			n, p := 0, rnd.Float64()
+			for p > limit {
+				n++
+				p *= rnd.Float64()
+			}
+			return n
+		}, nil
+	case "constant":
+		var due float64
+		return func(_ *rand.Rand) int {
+			due += perMinute
+			n := int(due)
+			due -= float64(n)
+			return n
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown arrival model: %s", name)
+	}
+}
+
+// sample is one point of the time series
+type sample struct {
+	at        time.Time
+	elapsed   time.Duration
+	report    burrows.Report
+	occupied  int
+	collapsed int
+	rented    int
+	rejected  int
+	departed  int
+}
+
+func simulate(snapshot burrows.Snapshot, arrivals arrivalModel, out seriesWriter) error {
+
+	logLevel := slog.LevelWarn
+	if verbose {
+		logLevel = slog.LevelDebug
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: logLevel}))
+
+	start := snapshot.Taken
+	if start.IsZero() {
+		start = time.Now().UTC().Truncate(time.Minute)
+	}
+	clock := burrows.NewFakeClock(start)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	manager := burrows.NewManager(ctx, logger, burrows.WithClock(clock), burrows.WithTact(time.Minute), burrows.WithDumpDir(""))
+	defer func() {
+		cancel()
+		<-manager.Done
+	}()
+
+	in := make(chan burrows.Burrow)
+	go func() {
+		defer close(in)
+		for _, b := range snapshot.Burrows {
+			in <- b
+		}
+	}()
+	manager.Load(in)
+
+	rnd := rand.New(rand.NewSource(simSeed))
+	var rented, rejected, departed, families int
+
+	// departures are the gophers that leave their burrow, by the minute they leave.
+	// The gophers of the snapshot leave one by one, the families of the rentals together
+	stays := newStayModel(simStay)
+	departures := make(map[time.Duration][]burrows.Burrow)
+	leave := func(elapsed time.Duration, name string, gophers []string) {
+		if stay := stays(rnd); stay > 0 {
+			departures[elapsed+stay] = append(departures[elapsed+stay], burrows.Burrow{Name: name, Occupants: gophers})
+		}
+	}
+	for _, b := range snapshot.Burrows {
+		for _, g := range b.Occupants {
+			leave(0, b.Name, []string{g})
+		}
+	}
+
+	record := func(elapsed time.Duration) error {
+		status := manager.CurrentStatus()
+		s := sample{
+			at:       start.Add(elapsed),
+			elapsed:  elapsed,
+			report:   burrows.NewReport(status),
+			rented:   rented,
+			rejected: rejected,
+			departed: departed,
+		}
+		for _, b := range status {
+			if b.IsOccupied() {
+				s.occupied++
+			}
+			if b.IsCollapsed() {
+				s.collapsed++
+			}
+		}
+		return out.Write(s)
+	}
+
+	if err := record(0); err != nil {
+		return err
+	}
+
+	// recorded is the time of the last point, the end of the simulation is a point even if it is not a whole step
+	var recorded time.Duration
+	for elapsed := time.Minute; elapsed <= simDuration; elapsed += time.Minute {
+		for _, d := range departures[elapsed] {
+			if _, err := manager.Vacate(d.Name, d.Occupants); err == nil {
+				departed += len(d.Occupants)
+			}
+		}
+		delete(departures, elapsed)
+
+		for range arrivals(rnd) {
+			families++
+			gophers := make([]string, 1+rnd.Intn(simFamilyMax))
+			for i := range gophers {
+				gophers[i] = fmt.Sprintf("sim-%d-%d", families, i+1)
+			}
+			home, err := manager.Rentout(ctx, burrows.Rental{Gophers: gophers})
+			if err != nil {
+				rejected++
+				continue
+			}
+			rented++
+			leave(elapsed, home.Name, gophers)
+		}
+
+		clock.Advance(time.Minute)
+
+		if elapsed%simStep == 0 {
+			if err := record(elapsed); err != nil {
+				return err
+			}
+			recorded = elapsed
+		}
+	}
+	if recorded != simDuration {
+		if err := record(simDuration); err != nil {
+			return err
+		}
+	}
+
+	return out.Flush()
+}
+
+type seriesWriter interface {
+	Write(s sample) error
+	Flush() error
+}
+
+var seriesHeader = []string{"TIME", "ELAPSED", "AVAILABLE", "FREE_SLOTS", "OCCUPIED", "COLLAPSED", "TOTAL_DEPTH", "RENTED", "REJECTED", "DEPARTED"}
+
+func newSeriesWriter(w io.Writer, format string) (seriesWriter, error) {
+	switch format {
+	case "text":
+		return &textSeries{w: tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)}, nil
+	case "csv":
+		return &csvSeries{w: csv.NewWriter(w)}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format: %s", format)
+	}
+}
+
+func (s sample) fields() []string {
+	return []string{
+		s.at.Format(time.RFC3339),
+		formatElapsed(s.elapsed),
+		strconv.Itoa(s.report.NumAvailable),
+		strconv.Itoa(s.report.FreeSlots),
+		strconv.Itoa(s.occupied),
+		strconv.Itoa(s.collapsed),
+		strconv.FormatFloat(s.report.TotalDepth, 'g', 7, 64),
+		strconv.Itoa(s.rented),
+		strconv.Itoa(s.rejected),
+		strconv.Itoa(s.departed),
+	}
+}
+
+// formatElapsed prints durations in days, ex: 2d03h00m
+func formatElapsed(d time.Duration) string {
+	days := d / (24 * time.Hour)
+	d -= days * 24 * time.Hour
+	return fmt.Sprintf("%dd%02dh%02dm", days, d/time.Hour, (d%time.Hour)/time.Minute)
+}
+
+type textSeries struct {
+	w      *tabwriter.Writer
+	header bool
+}
+
+func (t *textSeries) Write(s sample) error {
+	if !t.header {
+		t.header = true
+		if err := t.line(seriesHeader); err != nil {
+			return err
+		}
+	}
+	return t.line(s.fields())
+}
+
+func (t *textSeries) line(fields []string) error {
+	for _, f := range fields {
+		if _, err := fmt.Fprintf(t.w, "%s\t", f); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(t.w)
+	return err
+}
+
+func (t *textSeries) Flush() error { return t.w.Flush() }
+
+type csvSeries struct {
+	w      *csv.Writer
+	header bool
+}
+
+func (c *csvSeries) Write(s sample) error {
+	if !c.header {
+		c.header = true
+		if err := c.w.Write(seriesHeader); err != nil {
+			return err
+		}
+	}
+	return c.w.Write(s.fields())
+}
+
+func (c *csvSeries) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
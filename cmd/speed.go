@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/mehix/gopher-burrows/internal/burrows"
+	"github.com/spf13/cobra"
+)
+
+var serverURL string
+
+var cmdSpeed = &cobra.Command{
+	Use:   "speed",
+	Short: "Show or change how fast the burrows of a running server age",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return callSpeed(http.MethodGet, "/admin/speed", nil)
+	},
+}
+
+var cmdSpeedPause = &cobra.Command{
+	Use:   "pause",
+	Short: "Stop the aging of all the burrows",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return callSpeed(http.MethodPost, "/admin/speed/pause", nil)
+	},
+}
+
+var cmdSpeedResume = &cobra.Command{
+	Use:   "resume",
+	Short: "Restart the aging of all the burrows",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return callSpeed(http.MethodPost, "/admin/speed/resume", nil)
+	},
+}
+
+var cmdSpeedTact = &cobra.Command{
+	Use:     "tact DURATION",
+	Short:   "Change how often the burrows age by one minute",
+	Example: "  burrows speed tact 100ms",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return callSpeed(http.MethodPost, "/admin/speed/tact", url.Values{"value": args})
+	},
+}
+
+var cmdSpeedAdvance = &cobra.Command{
+	Use:     "advance DURATION",
+	Short:   "Fast-forward the life of all the burrows, even while paused",
+	Example: "  burrows speed advance 2d",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return callSpeed(http.MethodPost, "/admin/speed/advance", url.Values{"by": args})
+	},
+}
+
+func init() {
+	cmdSpeed.PersistentFlags().StringVar(&serverURL, "server", "http://127.0.0.1:8080", "address of the running server")
+	cmdSpeed.AddCommand(cmdSpeedPause, cmdSpeedResume, cmdSpeedTact, cmdSpeedAdvance)
+
+	// errors come from the server, the usage would not help
+	for _, c := range []*cobra.Command{cmdSpeed, cmdSpeedPause, cmdSpeedResume, cmdSpeedTact, cmdSpeedAdvance} {
+		c.SilenceUsage = true
+	}
+}
+
+func callSpeed(method, path string, query url.Values) error {
+	u, err := url.JoinPath(serverURL, path)
+	if err != nil {
+		return err
+	}
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s: %s", resp.Status, msg)
+	}
+
+	var speed burrows.Speed
+	if err := json.NewDecoder(resp.Body).Decode(&speed); err != nil {
+		return err
+	}
+
+	sfmt := "%-20s %v\n"
+	fmt.Fprintf(os.Stdout, sfmt, "Tact", speed.Tact)
+	fmt.Fprintf(os.Stdout, sfmt, "Paused", speed.Paused)
+	return nil
+}
@@ -1,21 +1,113 @@
 package burrows
 
-import "math"
+import (
+	"maps"
+	"math"
+	"slices"
+)
 
 const maxAgeInMin int = 25 * 24 * 60 // 25 days
 
 type Burrow struct {
-	Name     string  `json:"name"`
-	Occupied bool    `json:"occupied"`
-	Depth    float64 `json:"depth"`
-	Width    float64 `json:"width"`
-	AgeInMin int     `json:"age"`
+	Name      string            `json:"name"`
+	Capacity  int               `json:"capacity"`
+	Occupants []string          `json:"occupants,omitempty"`
+	Depth     float64           `json:"depth"`
+	Width     float64           `json:"width"`
+	AgeInMin  int               `json:"age"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	// Removed marks a record of a data file whose burrow is decommissioned. Managed burrows never have it set
+	Removed bool `json:"removed,omitempty"`
 }
 
-// IsAvailable returns `true` if the burrow is not occupied by a gopher and if it hasn't already collapsed.
+// Slots returns the number of gophers the burrow can host.
+// Burrows without an explicit capacity host a single gopher.
+func (b *Burrow) Slots() int {
+	if b.Capacity < 1 {
+		return 1
+	}
+	return b.Capacity
+}
+
+// FreeSlots returns how many more gophers can move into the burrow.
+// A collapsed burrow has no free slots.
+func (b *Burrow) FreeSlots() int {
+	if b.IsCollapsed() {
+		return 0
+	}
+	return max(b.Slots()-len(b.Occupants), 0)
+}
+
+// IsOccupied returns `true` if at least one gopher lives in the burrow.
+func (b *Burrow) IsOccupied() bool {
+	return len(b.Occupants) > 0
+}
+
+// IsCollapsed returns `true` once the burrow reached its maximum age.
 // A burrow collapses automatically after exactly 25 days
+func (b *Burrow) IsCollapsed() bool {
+	return b.AgeInMin >= maxAgeInMin
+}
+
+// IsAvailable returns `true` if the burrow has free capacity and if it hasn't already collapsed.
 func (b *Burrow) IsAvailable() bool {
-	return !b.Occupied && b.AgeInMin < maxAgeInMin
+	return b.FreeSlots() > 0
+}
+
+// MoveIn adds the gophers to the occupants of the burrow.
+// It returns `false` and leaves the burrow untouched if there is not enough free capacity for all of them.
+func (b *Burrow) MoveIn(gophers ...string) bool {
+	if len(gophers) == 0 || len(gophers) > b.FreeSlots() {
+		return false
+	}
+	for i, g := range gophers {
+		if slices.Contains(b.Occupants, g) || slices.Contains(gophers[:i], g) {
+			return false
+		}
+	}
+	b.Occupants = append(slices.Clone(b.Occupants), gophers...)
+	return true
+}
+
+// MoveOut removes the gophers from the occupants of the burrow.
+// It returns `false` and leaves the burrow untouched if one of them does not live in the burrow.
+func (b *Burrow) MoveOut(gophers ...string) bool {
+	if len(gophers) == 0 {
+		return false
+	}
+	for _, g := range gophers {
+		if !slices.Contains(b.Occupants, g) {
+			return false
+		}
+	}
+	b.Occupants = slices.DeleteFunc(slices.Clone(b.Occupants), func(o string) bool { return slices.Contains(gophers, o) })
+	if len(b.Occupants) == 0 {
+		b.Occupants = nil
+	}
+	return true
+}
+
+// Reconcile takes over the attributes that the inventory owns from the record: the capacity, the width and the labels.
+// Records can be partial, attributes without a value in the record are kept. Empty, but not missing, labels remove all the labels.
+// The live state of the burrow, its occupants, age and depth, is kept. A smaller capacity never evicts an occupant.
+// It returns `false` if nothing changed.
+func (b *Burrow) Reconcile(record Burrow) bool {
+	changed := *b
+	if record.Capacity != 0 {
+		changed.Capacity = record.Capacity
+	}
+	if record.Width != 0 {
+		changed.Width = record.Width
+	}
+	if record.Labels != nil {
+		changed.Labels = maps.Clone(record.Labels)
+	}
+
+	if changed.Capacity == b.Capacity && changed.Width == b.Width && maps.Equal(changed.Labels, b.Labels) {
+		return false
+	}
+	*b = changed
+	return true
 }
 
 // Volume returns the volume of the burrow.
@@ -24,6 +116,31 @@ func (b *Burrow) Volume() float64 {
 	return b.Depth * math.Pi * math.Pow(b.Width, 2) / 4
 }
 
+// AgeBy advances the age of the burrow by the given number of minutes in one step.
+// It has the same effect as calling `IncrementAge` for every minute, which matters when
+// a burrow has to catch up on a long period of time.
+func (b *Burrow) AgeBy(mins int) {
+	mins = min(mins, maxAgeInMin-b.AgeInMin)
+	if mins <= 0 {
+		return
+	}
+
+	b.AgeInMin += mins
+	if !b.IsOccupied() {
+		return
+	}
+	if b.Depth == 0.0 {
+		b.Depth = 0.009
+		mins--
+	}
+	// every minute the depth changes by 0.9% of its absolute value
+	if b.Depth > 0 {
+		b.Depth *= math.Pow(1.009, float64(mins))
+	} else {
+		b.Depth *= math.Pow(0.991, float64(mins))
+	}
+}
+
 // IncrementAge advances the by 1 minute.
 // If the burrow is occupied it also updates the depth. It handles "negative" depths as well.
 func (b *Burrow) IncrementAge() {
@@ -32,7 +149,7 @@ func (b *Burrow) IncrementAge() {
 	}
 
 	b.AgeInMin++
-	if b.Occupied {
+	if b.IsOccupied() {
 		if b.Depth == 0.0 {
 			b.Depth = 0.009
 		} else {
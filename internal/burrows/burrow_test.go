@@ -2,6 +2,8 @@ package burrows
 
 import (
 	"math"
+	"reflect"
+	"slices"
 	"testing"
 )
 
@@ -27,8 +29,8 @@ func TestIncreaseDepth(t *testing.T) {
 		mins  int     // minutes passed
 		depth float64 // expected depth
 	}{
-		{b: Burrow{Name: "new", Depth: 0, Occupied: true}, mins: 1, depth: 0.009},
-		{b: Burrow{Name: "new free", Depth: 0, Occupied: false}, mins: 1, depth: 0},
+		{b: Burrow{Name: "new", Depth: 0, Occupants: []string{"gopher"}}, mins: 1, depth: 0.009},
+		{b: Burrow{Name: "new free", Depth: 0}, mins: 1, depth: 0},
 	}
 
 	for _, s := range scenarios {
@@ -52,13 +54,16 @@ func TestIsAvailable(t *testing.T) {
 		b         Burrow
 		available bool
 	}{
-		{b: Burrow{Name: "one min to collapse free", AgeInMin: maxAgeInMin - 1, Occupied: false}, available: true},
-		{b: Burrow{Name: "one min to collapse occupied", AgeInMin: maxAgeInMin - 1, Occupied: true}, available: false},
+		{b: Burrow{Name: "one min to collapse free", AgeInMin: maxAgeInMin - 1}, available: true},
+		{b: Burrow{Name: "one min to collapse occupied", AgeInMin: maxAgeInMin - 1, Occupants: []string{"gopher"}}, available: false},
 		{b: Burrow{Name: "collapsing", AgeInMin: maxAgeInMin}, available: false},
 		{b: Burrow{Name: "just collapsed", AgeInMin: maxAgeInMin + 1}, available: false},
 		{b: Burrow{Name: "long collapsed", AgeInMin: maxAgeInMin + 100}, available: false},
-		{b: Burrow{Name: "good free", AgeInMin: 19, Occupied: false}, available: true},
-		{b: Burrow{Name: "good occupied", AgeInMin: 19, Occupied: true}, available: false},
+		{b: Burrow{Name: "good free", AgeInMin: 19}, available: true},
+		{b: Burrow{Name: "good occupied", AgeInMin: 19, Occupants: []string{"gopher"}}, available: false},
+		{b: Burrow{Name: "family partially occupied", AgeInMin: 19, Capacity: 3, Occupants: []string{"mum", "dad"}}, available: true},
+		{b: Burrow{Name: "family full", AgeInMin: 19, Capacity: 2, Occupants: []string{"mum", "dad"}}, available: false},
+		{b: Burrow{Name: "family collapsed", AgeInMin: maxAgeInMin, Capacity: 3}, available: false},
 	}
 
 	for _, s := range scenarios {
@@ -81,10 +86,10 @@ func TestIncreaseAge(t *testing.T) {
 		mins      int // minutes passed
 		available bool
 	}{
-		{b: &Burrow{Name: "collapsed free", Occupied: false, AgeInMin: 10}, mins: maxAgeInMin - 9, available: false},
-		{b: &Burrow{Name: "collapsed occupied", Occupied: true, AgeInMin: 10}, mins: maxAgeInMin - 9, available: false},
-		{b: &Burrow{Name: "not collapsed free", Occupied: false, AgeInMin: 10}, mins: maxAgeInMin - 11, available: true},
-		{b: &Burrow{Name: "not collapsed occupied", Occupied: true, AgeInMin: 10}, mins: maxAgeInMin - 11, available: false},
+		{b: &Burrow{Name: "collapsed free", AgeInMin: 10}, mins: maxAgeInMin - 9, available: false},
+		{b: &Burrow{Name: "collapsed occupied", Occupants: []string{"gopher"}, AgeInMin: 10}, mins: maxAgeInMin - 9, available: false},
+		{b: &Burrow{Name: "not collapsed free", AgeInMin: 10}, mins: maxAgeInMin - 11, available: true},
+		{b: &Burrow{Name: "not collapsed occupied", Occupants: []string{"gopher"}, AgeInMin: 10}, mins: maxAgeInMin - 11, available: false},
 	}
 
 	for _, s := range scenarios {
@@ -103,3 +108,136 @@ func TestIncreaseAge(t *testing.T) {
 		})
 	}
 }
+
+func TestMoveIn(t *testing.T) {
+
+	scenarios := []struct {
+		name     string
+		b        Burrow
+		gophers  []string
+		accepted bool
+		free     int // expected free slots afterwards
+	}{
+		{name: "single into empty", b: Burrow{}, gophers: []string{"a"}, accepted: true, free: 0},
+		{name: "family into empty", b: Burrow{Capacity: 4}, gophers: []string{"a", "b", "c"}, accepted: true, free: 1},
+		{name: "family into partial", b: Burrow{Capacity: 4, Occupants: []string{"a"}}, gophers: []string{"b", "c"}, accepted: true, free: 1},
+		{name: "family too large", b: Burrow{Capacity: 4, Occupants: []string{"a", "b"}}, gophers: []string{"c", "d", "e"}, accepted: false, free: 2},
+		{name: "already living there", b: Burrow{Capacity: 4, Occupants: []string{"a"}}, gophers: []string{"a"}, accepted: false, free: 3},
+		{name: "same gopher twice", b: Burrow{Capacity: 4}, gophers: []string{"a", "a"}, accepted: false, free: 4},
+		{name: "nobody", b: Burrow{Capacity: 4}, accepted: false, free: 4},
+		{name: "collapsed", b: Burrow{Capacity: 4, AgeInMin: maxAgeInMin}, gophers: []string{"a"}, accepted: false, free: 0},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			t.Parallel()
+
+			if ok := s.b.MoveIn(s.gophers...); ok != s.accepted {
+				t.Errorf("wrong move in result. expected: %v, got: %v", s.accepted, ok)
+			}
+
+			if free := s.b.FreeSlots(); free != s.free {
+				t.Errorf("wrong free slots. expected: %d, got: %d", s.free, free)
+			}
+		})
+	}
+}
+
+func TestMoveOut(t *testing.T) {
+
+	scenarios := []struct {
+		name      string
+		b         Burrow
+		gophers   []string
+		accepted  bool
+		occupants []string // expected occupants afterwards
+	}{
+		{name: "one of a family", b: Burrow{Capacity: 3, Occupants: []string{"a", "b", "c"}}, gophers: []string{"b"}, accepted: true, occupants: []string{"a", "c"}},
+		{name: "everybody", b: Burrow{Capacity: 2, Occupants: []string{"a", "b"}}, gophers: []string{"b", "a"}, accepted: true},
+		{name: "stranger", b: Burrow{Capacity: 2, Occupants: []string{"a"}}, gophers: []string{"a", "x"}, accepted: false, occupants: []string{"a"}},
+		{name: "nobody", b: Burrow{Occupants: []string{"a"}}, accepted: false, occupants: []string{"a"}},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			t.Parallel()
+
+			if ok := s.b.MoveOut(s.gophers...); ok != s.accepted {
+				t.Errorf("wrong move out result. expected: %v, got: %v", s.accepted, ok)
+			}
+
+			if !slices.Equal(s.b.Occupants, s.occupants) {
+				t.Errorf("wrong occupants. expected: %v, got: %v", s.occupants, s.b.Occupants)
+			}
+		})
+	}
+}
+
+func TestAgeBy(t *testing.T) {
+
+	scenarios := []struct {
+		b    Burrow
+		mins int
+	}{
+		{b: Burrow{Name: "free", Depth: 1.5, AgeInMin: 10}, mins: 2 * 24 * 60},
+		{b: Burrow{Name: "occupied", Depth: 1.5, AgeInMin: 10, Occupants: []string{"gopher"}}, mins: 2 * 24 * 60},
+		{b: Burrow{Name: "new occupied", Depth: 0, Occupants: []string{"gopher"}}, mins: 300},
+		{b: Burrow{Name: "negative occupied", Depth: -2, Occupants: []string{"gopher"}}, mins: 300},
+		{b: Burrow{Name: "collapses meanwhile", Depth: 1, AgeInMin: maxAgeInMin - 10, Occupants: []string{"gopher"}}, mins: 60},
+		{b: Burrow{Name: "already collapsed", Depth: 1, AgeInMin: maxAgeInMin, Occupants: []string{"gopher"}}, mins: 60},
+		{b: Burrow{Name: "nothing", Depth: 1, Occupants: []string{"gopher"}}, mins: 0},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.b.Name, func(t *testing.T) {
+			t.Parallel()
+
+			expected := s.b
+			for range s.mins {
+				expected.IncrementAge()
+			}
+
+			got := s.b
+			got.AgeBy(s.mins)
+
+			if got.AgeInMin != expected.AgeInMin {
+				t.Errorf("wrong age. expected: %d, got: %d", expected.AgeInMin, got.AgeInMin)
+			}
+			if math.Abs(got.Depth-expected.Depth) > 1e-6*math.Max(1, math.Abs(expected.Depth)) {
+				t.Errorf("wrong depth. expected: %f, got: %f", expected.Depth, got.Depth)
+			}
+		})
+	}
+}
+
+func TestReconcile(t *testing.T) {
+
+	live := Burrow{Name: "b", Capacity: 2, Occupants: []string{"a", "b"}, Depth: 1.5, Width: 1, AgeInMin: 60, Labels: map[string]string{"site": "north"}}
+
+	scenarios := []struct {
+		name     string
+		record   Burrow
+		changed  bool
+		expected Burrow
+	}{
+		{name: "same", record: Burrow{Name: "b", Capacity: 2, Width: 1, Labels: map[string]string{"site": "north"}}, changed: false, expected: live},
+		{name: "partial", record: Burrow{Name: "b", Capacity: 4}, changed: true,
+			expected: Burrow{Name: "b", Capacity: 4, Occupants: []string{"a", "b"}, Depth: 1.5, Width: 1, AgeInMin: 60, Labels: map[string]string{"site": "north"}}},
+		{name: "smaller", record: Burrow{Name: "b", Capacity: 1, Depth: 9, AgeInMin: 1, Labels: map[string]string{}}, changed: true,
+			expected: Burrow{Name: "b", Capacity: 1, Occupants: []string{"a", "b"}, Depth: 1.5, Width: 1, AgeInMin: 60, Labels: map[string]string{}}},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			t.Parallel()
+
+			b := live
+			if changed := b.Reconcile(s.record); changed != s.changed {
+				t.Errorf("wrong reconcile result. expected: %v, got: %v", s.changed, changed)
+			}
+			if !reflect.DeepEqual(s.expected, b) {
+				t.Errorf("wrong burrow. expected: %+v, got: %+v", s.expected, b)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package burrows
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the time and drives the aging of the burrows.
+// Use `RealClock` in production and a `FakeClock` to control the time in tests.
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker delivers ticks at intervals, like a `time.Ticker`.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// RealClock is backed by the wall clock.
+var RealClock Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) NewTicker(d time.Duration) Ticker { return realTicker{time.NewTicker(d)} }
+
+type realTicker struct{ *time.Ticker }
+
+func (t realTicker) C() <-chan time.Time { return t.Ticker.C }
+
+// FakeClock only moves forward when `Advance` is called.
+// Unlike a real ticker, its tickers never drop ticks: `Advance` blocks until every tick
+// that became due was received, so once it returns all the burrows aged by the same amount.
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+type fakeTicker struct {
+	clock  *FakeClock
+	period time.Duration
+	next   time.Time
+	c      chan time.Time
+	done   chan struct{}
+	once   sync.Once
+}
+
+// NewFakeClock returns a clock that starts at the given time.
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for FakeClock.NewTicker")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &fakeTicker{
+		clock:  c,
+		period: d,
+		next:   c.now.Add(d),
+		c:      make(chan time.Time),
+		done:   make(chan struct{}),
+	}
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+// Advance moves the clock forward and fires, in chronological order, all the ticks that became due.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	target := c.now.Add(d)
+	c.mu.Unlock()
+
+	for {
+		c.mu.Lock()
+		var due *fakeTicker
+		for _, t := range c.tickers {
+			if !t.next.After(target) && (due == nil || t.next.Before(due.next)) {
+				due = t
+			}
+		}
+		if due == nil {
+			c.now = target
+			c.mu.Unlock()
+			return
+		}
+		now := due.next
+		c.now = now
+		due.next = due.next.Add(due.period)
+		c.mu.Unlock()
+
+		// the lock is released so that the receiver can use the clock while handling the tick
+		select {
+		case due.c <- now:
+		case <-due.done:
+		}
+	}
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.c }
+
+func (t *fakeTicker) Stop() {
+	t.once.Do(func() {
+		close(t.done)
+
+		t.clock.mu.Lock()
+		defer t.clock.mu.Unlock()
+		for i, other := range t.clock.tickers {
+			if other == t {
+				t.clock.tickers = append(t.clock.tickers[:i], t.clock.tickers[i+1:]...)
+				break
+			}
+		}
+	})
+}
@@ -0,0 +1,62 @@
+package burrows
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClockAdvance(t *testing.T) {
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+
+	fast, slow := clock.NewTicker(time.Minute), clock.NewTicker(5*time.Minute)
+	defer fast.Stop()
+
+	counts := make(chan [2]int)
+	go func() {
+		var c [2]int
+		for {
+			select {
+			case <-fast.C():
+				c[0]++
+			case <-slow.C():
+				c[1]++
+				if c[1] == 2 {
+					counts <- c
+					return
+				}
+			}
+		}
+	}()
+
+	clock.Advance(10 * time.Minute)
+
+	if c := <-counts; c != [2]int{10, 2} {
+		t.Errorf("wrong number of ticks. expected: [10 2], got: %v", c)
+	}
+
+	if now := clock.Now(); !now.Equal(start.Add(10 * time.Minute)) {
+		t.Errorf("wrong time after advancing the clock: %v", now)
+	}
+}
+
+func TestFakeClockStoppedTicker(t *testing.T) {
+
+	clock := NewFakeClock(time.Now())
+
+	tkr := clock.NewTicker(time.Second)
+	tkr.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		clock.Advance(time.Hour)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("advancing the clock blocked on a stopped ticker")
+	}
+}
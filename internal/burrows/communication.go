@@ -3,24 +3,41 @@ package burrows
 type requestType string
 
 const (
-	ReqStatus    requestType = "status"
-	ReqAvailable requestType = "available"
-	ReqGopher    requestType = "gopher"
-	ReqClose     requestType = "close"
+	ReqStatus     requestType = "status"
+	ReqGopher     requestType = "gopher"
+	ReqLabels     requestType = "labels"
+	ReqVacate     requestType = "vacate"
+	ReqAdd        requestType = "add"
+	ReqSync       requestType = "sync"
+	ReqTick       requestType = "tick"
+	ReqClose      requestType = "close"
+	ReqCheckpoint requestType = "checkpoint"
 )
 
-// Response from a burrow to the manager.
-// Should contain the current status and may also contain a new request channel
-// if further instructions are expected from the manager
+// Response from a shard to the manager.
+// Should contain the current status of the burrows of the shard or of the burrow targeted
+// by the request. May also contain an error if the request could not be fulfilled
 type Response struct {
-	burrow      Burrow
-	nextRequest chan Request
+	burrow  Burrow
+	burrows []Burrow
+	// matched are the names of the records of a sync request that the shard owns
+	matched []string
+	// removed are the burrows that a sync request decommissioned
+	removed []Burrow
+	err     error
 }
 
-// Request is a request from the manager to a burrow.
-// It provides a channel where the burrow can send its response
+// Request is a request from the manager to a shard.
+// It provides a channel where the shard can send its response.
+// Requests that target a single burrow identify it by name
 type Request struct {
 	name     requestType
+	burrow   string
+	gophers  []string
+	labels   map[string]string
+	add      []Burrow
+	records  []Burrow
+	minutes  int
 	response chan Response
 }
 
@@ -31,16 +48,59 @@ func NewStatusRequest(resp chan Response) Request {
 	}
 }
 
-func NewAvailableRequest() Request {
+// NewGopherRequest asks the burrow with the given name to host all the gophers.
+// The response channel is buffered so the shard never blocks if the manager stopped waiting.
+func NewGopherRequest(name string, gophers []string) Request {
 	return Request{
-		name:     ReqAvailable,
-		response: make(chan Response, 1), // we are only interested in the first available burrow, the rest of the responses are discarded.
+		name:     ReqGopher,
+		burrow:   name,
+		gophers:  gophers,
+		response: make(chan Response, 1),
 	}
 }
 
-func NewGopherRequest() Request {
+// NewLabelsRequest asks the burrow with the given name to replace its labels.
+// Shards that don't have the burrow respond with `ErrUnknownBurrow`.
+func NewLabelsRequest(name string, labels map[string]string, resp chan Response) Request {
 	return Request{
-		name:     ReqGopher,
-		response: make(chan Response),
+		name:     ReqLabels,
+		burrow:   name,
+		labels:   labels,
+		response: resp,
+	}
+}
+
+// NewVacateRequest asks the burrow with the given name to let the gophers move out.
+// Without gophers all the occupants move out.
+// Shards that don't have the burrow respond with `ErrUnknownBurrow`.
+func NewVacateRequest(name string, gophers []string, resp chan Response) Request {
+	return Request{
+		name:     ReqVacate,
+		burrow:   name,
+		gophers:  gophers,
+		response: resp,
 	}
 }
+
+// NewAddRequest hands over new burrows to a shard. No response is expected.
+func NewAddRequest(b []Burrow) Request {
+	return Request{
+		name: ReqAdd,
+		add:  b,
+	}
+}
+
+// NewSyncRequest asks a shard to reconcile the burrows it owns with the records of the inventory.
+// The shard responds with the burrows that changed, the names of the records it owns and the burrows it decommissioned.
+func NewSyncRequest(records []Burrow, resp chan Response) Request {
+	return Request{
+		name:     ReqSync,
+		records:  records,
+		response: resp,
+	}
+}
+
+// NewTickRequest asks a shard to age all of its burrows by the given minutes. No response is expected.
+func NewTickRequest(minutes int) Request {
+	return Request{name: ReqTick, minutes: minutes}
+}
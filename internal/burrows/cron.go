@@ -0,0 +1,209 @@
+package burrows
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cron is a cron expression: minute, hour, day of the month, month and day of the week, ex: `0 0 * * *`.
+// Fields are lists of values, ranges and steps, ex: `0,30`, `9-17` or `*/15`. Months and days of the week
+// can be named, ex: `MON-FRI`. When both days are restricted, a day that matches either of them matches.
+//
+// The macros `@yearly`, `@monthly`, `@weekly`, `@daily` and `@hourly` are available, and `@every 10m` runs
+// at a fixed interval, like a ticker. Expressions are in the local time zone unless prefixed with
+// `CRON_TZ=<zone>`, ex: `CRON_TZ=UTC 0 0 * * *` is midnight UTC.
+type Cron struct {
+	expr string
+	loc  *time.Location
+	// every is the interval of `@every`, the fields are ignored then
+	every time.Duration
+	// the fields are sets of bits, ex: bit 5 of the hours is set if the expression runs at 5 o'clock
+	minute, hour, dom, month, dow uint64
+	// domAny and dowAny are set if the day field is `*`
+	domAny, dowAny bool
+}
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronField is the range of the values of a field and the names of the values, if any
+type cronField struct {
+	name     string
+	min, max int
+	names    []string
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of the month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: []string{"", "JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"}},
+	// Sunday is 0 and 7
+	{name: "day of the week", min: 0, max: 7, names: []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}},
+}
+
+// ParseCron parses a cron expression.
+func ParseCron(expr string) (Cron, error) {
+	c := Cron{expr: strings.TrimSpace(expr), loc: time.Local}
+
+	spec := c.expr
+	if tz, ok := strings.CutPrefix(spec, "CRON_TZ="); ok {
+		zone, rest, _ := strings.Cut(tz, " ")
+		loc, err := time.LoadLocation(zone)
+		if err != nil {
+			return Cron{}, fmt.Errorf("invalid time zone of cron expression %q: %w", expr, err)
+		}
+		c.loc, spec = loc, strings.TrimSpace(rest)
+	}
+
+	if interval, ok := strings.CutPrefix(spec, "@every "); ok {
+		d, err := ParseDuration(strings.TrimSpace(interval))
+		if err != nil || d <= 0 {
+			return Cron{}, fmt.Errorf("invalid interval of cron expression %q", expr)
+		}
+		c.every = d
+		return c, nil
+	}
+	if macro, ok := cronMacros[spec]; ok {
+		spec = macro
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != len(cronFields) {
+		return Cron{}, fmt.Errorf("cron expression %q should have %d fields, has %d", expr, len(cronFields), len(fields))
+	}
+	bits := make([]uint64, len(fields))
+	for i, f := range fields {
+		b, err := cronFields[i].parse(f)
+		if err != nil {
+			return Cron{}, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+		}
+		bits[i] = b
+	}
+	c.minute, c.hour, c.dom, c.month, c.dow = bits[0], bits[1], bits[2], bits[3], bits[4]
+	// like in vixie cron, a day field that starts with a star does not restrict the days, ex: `*/2`
+	c.domAny, c.dowAny = strings.HasPrefix(fields[2], "*"), strings.HasPrefix(fields[4], "*")
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+
+	return c, nil
+}
+
+// parse returns the bits of the values of a field, ex: `1-5/2` sets the bits 1, 3 and 5
+func (f cronField) parse(s string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(s, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepStr); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step of the %s: %s", f.name, part)
+			}
+		}
+
+		lo, hi := f.min, f.max
+		if rng != "*" {
+			first, last, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = f.value(first); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = f.value(last); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				hi = f.max
+			}
+			if hi < lo {
+				return 0, fmt.Errorf("invalid range of the %s: %s", f.name, part)
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (f cronField) value(s string) (int, error) {
+	for i, name := range f.names {
+		if name != "" && strings.EqualFold(s, name) {
+			return i, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid %s: %s", f.name, s)
+	}
+	return v, nil
+}
+
+// Next returns the first time after `after` that the expression matches.
+// It returns the zero time if the expression never matches, ex: on February 30th.
+func (c Cron) Next(after time.Time) time.Time {
+	if c.every > 0 {
+		return after.Add(c.every)
+	}
+	if c.loc == nil {
+		return time.Time{}
+	}
+
+	t := after.In(c.loc).Truncate(time.Minute).Add(time.Minute)
+	// every valid expression matches within 8 years, the longest gap between two leap days
+	limit := t.AddDate(8, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, c.loc)
+		case !c.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, c.loc)
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, c.loc)
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (c Cron) matchesDay(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domAny || c.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+func (c Cron) String() string { return c.expr }
+
+// MarshalText allows a cron expression to be used in JSON and YAML documents
+func (c Cron) MarshalText() ([]byte, error) {
+	return []byte(c.expr), nil
+}
+
+// UnmarshalText parses a cron expression from JSON and YAML documents
+func (c *Cron) UnmarshalText(text []byte) error {
+	parsed, err := ParseCron(string(text))
+	if err != nil {
+		return err
+	}
+	*c = parsed
+	return nil
+}
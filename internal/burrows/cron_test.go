@@ -0,0 +1,74 @@
+package burrows
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+
+	// a Friday
+	from := time.Date(2024, 3, 1, 10, 17, 30, 0, time.UTC)
+
+	scenarios := []struct {
+		expr     string
+		expected time.Time
+	}{
+		{expr: "CRON_TZ=UTC * * * * *", expected: time.Date(2024, 3, 1, 10, 18, 0, 0, time.UTC)},
+		{expr: "CRON_TZ=UTC @hourly", expected: time.Date(2024, 3, 1, 11, 0, 0, 0, time.UTC)},
+		{expr: "CRON_TZ=UTC */15 * * * *", expected: time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC)},
+		{expr: "CRON_TZ=UTC 0 0 * * *", expected: time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)},
+		{expr: "CRON_TZ=UTC 30 9-17/4 * * MON-FRI", expected: time.Date(2024, 3, 1, 13, 30, 0, 0, time.UTC)},
+		{expr: "CRON_TZ=UTC 0 9 * * mon", expected: time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC)},
+		{expr: "CRON_TZ=UTC 0 0 * * 7", expected: time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC)},
+		// either day matches when both are restricted
+		{expr: "CRON_TZ=UTC 0 0 15 * SUN", expected: time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC)},
+		// a day field that starts with a star does not restrict the days, both have to match
+		{expr: "CRON_TZ=UTC 0 0 */2 * 1", expected: time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)},
+		{expr: "CRON_TZ=UTC 0 0 13 * */1", expected: time.Date(2024, 3, 13, 0, 0, 0, 0, time.UTC)},
+		{expr: "CRON_TZ=UTC 0 0 29 FEB *", expected: time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{expr: "CRON_TZ=UTC @monthly", expected: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{expr: "CRON_TZ=Europe/Berlin 0 0 * * *", expected: time.Date(2024, 3, 1, 23, 0, 0, 0, time.UTC)},
+		{expr: "@every 90m", expected: from.Add(90 * time.Minute)},
+		{expr: "@every 1d", expected: from.Add(24 * time.Hour)},
+		{expr: "CRON_TZ=UTC 0 0 30 2 *"},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.expr, func(t *testing.T) {
+			t.Parallel()
+
+			c, err := ParseCron(s.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if next := c.Next(from); !next.Equal(s.expected) {
+				t.Errorf("wrong next time. expected: %v, got: %v", s.expected, next)
+			}
+		})
+	}
+}
+
+func TestParseCronInvalid(t *testing.T) {
+
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"* * * JANUARY *",
+		"@every",
+		"@every -1h",
+		"@fortnightly",
+		"CRON_TZ=Mars/Olympus 0 0 * * *",
+	} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("%q should be refused", expr)
+		}
+	}
+}
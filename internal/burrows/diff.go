@@ -0,0 +1,210 @@
+package burrows
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// ReportDiff compares the burrows at two points in time.
+type ReportDiff struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// Detailed is set if the burrows of both reports are known, ex: when comparing dumps.
+	// Otherwise only the metrics are compared and the lists of burrows are empty
+	Detailed bool `json:"detailed"`
+	// Added and Removed are the burrows that only exist at one of the times
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+	// Occupied, Vacated and Collapsed are the burrows of both times that changed
+	Occupied  []string `json:"occupied"`
+	Vacated   []string `json:"vacated"`
+	Collapsed []string `json:"collapsed"`
+	// Metrics are the changes of the summary of the report
+	Metrics []MetricChange `json:"metrics"`
+}
+
+// MetricChange is the change of a metric of the report between two times.
+type MetricChange struct {
+	Name  string  `json:"name"`
+	From  float64 `json:"from"`
+	To    float64 `json:"to"`
+	Delta float64 `json:"delta"`
+}
+
+// DiffReports compares two reports. The burrows are compared one by one if both reports have them,
+// which is the case of the reports of the manager and of `NewSnapshotReport`, not of decoded reports.
+func DiffReports(from, to Report) ReportDiff {
+	d := ReportDiff{
+		From:      from.Taken,
+		To:        to.Taken,
+		Detailed:  from.Burrows != nil && to.Burrows != nil,
+		Added:     []string{},
+		Removed:   []string{},
+		Occupied:  []string{},
+		Vacated:   []string{},
+		Collapsed: []string{},
+	}
+
+	metric := func(name string, from, to float64) {
+		d.Metrics = append(d.Metrics, MetricChange{Name: name, From: from, To: to, Delta: to - from})
+	}
+	metric("totalDepth", from.TotalDepth, to.TotalDepth)
+	metric("numAvailable", float64(from.NumAvailable), float64(to.NumAvailable))
+	metric("freeSlots", float64(from.FreeSlots), float64(to.FreeSlots))
+	metric("count", float64(from.Count), float64(to.Count))
+	metric("occupants", float64(from.Occupants), float64(to.Occupants))
+	metric("occupancyRate", from.OccupancyRate, to.OccupancyRate)
+	metric("collapsed", float64(from.Collapsed), float64(to.Collapsed))
+	metric("totalVolume", from.TotalVolume, to.TotalVolume)
+
+	if !d.Detailed {
+		return d
+	}
+
+	before := make(map[string]Burrow, len(from.Burrows))
+	for _, b := range from.Burrows {
+		before[b.Name] = b
+	}
+	after := make(map[string]bool, len(to.Burrows))
+	for _, b := range to.Burrows {
+		after[b.Name] = true
+		old, ok := before[b.Name]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, b.Name)
+			continue
+		case !old.IsOccupied() && b.IsOccupied():
+			d.Occupied = append(d.Occupied, b.Name)
+		case old.IsOccupied() && !b.IsOccupied():
+			d.Vacated = append(d.Vacated, b.Name)
+		}
+		if !old.IsCollapsed() && b.IsCollapsed() {
+			d.Collapsed = append(d.Collapsed, b.Name)
+		}
+	}
+	for _, b := range from.Burrows {
+		if !after[b.Name] {
+			d.Removed = append(d.Removed, b.Name)
+		}
+	}
+
+	for _, names := range [][]string{d.Added, d.Removed, d.Occupied, d.Vacated, d.Collapsed} {
+		slices.Sort(names)
+	}
+	return d
+}
+
+// NewSnapshotReport summarizes the burrows of a snapshot with matching labels, as they were when it was taken.
+// The records of decommissioned burrows of data files are left out.
+func NewSnapshotReport(s Snapshot, sel Selector) Report {
+	burrows := []Burrow{}
+	for _, b := range Filter(s.Burrows, sel) {
+		if !b.Removed {
+			burrows = append(burrows, b)
+		}
+	}
+	r := NewReport(burrows)
+	r.Taken, r.Burrows = s.Taken, burrows
+	return r
+}
+
+// ReadDiffReport reads a report encoded in JSON, ex: a report file, or summarizes the burrows of a dump or data file.
+// JSON reports are told apart from JSON snapshots by their `totalDepth`. The burrows of the snapshots are restricted
+// to the ones with matching labels, reports are refused if `sel` is not empty because their burrows are unknown.
+func ReadDiffReport(r io.Reader, format Format, sel Selector) (Report, error) {
+	if format == FormatJSON {
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return Report{}, err
+		}
+		var probe struct {
+			TotalDepth *float64 `json:"totalDepth"`
+		}
+		if err := json.Unmarshal(b, &probe); err == nil && probe.TotalDepth != nil {
+			if !sel.Empty() {
+				return Report{}, errors.New("reports can not be restricted by labels, only dumps and data files")
+			}
+			var rep Report
+			err := json.Unmarshal(b, &rep)
+			return rep, err
+		}
+		r = bytes.NewReader(b)
+	}
+
+	dec, err := NewDecoder(r, format)
+	if err != nil {
+		return Report{}, err
+	}
+	s := Snapshot{Taken: dec.Taken()}
+	for {
+		b, err := dec.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return Report{}, err
+		}
+		s.Burrows = append(s.Burrows, b)
+	}
+	return NewSnapshotReport(s, sel), nil
+}
+
+// Write prints the changes of the metrics, then the burrows that changed if they are known.
+func (d ReportDiff) Write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	taken := func(t time.Time) string {
+		if t.IsZero() {
+			return "unknown"
+		}
+		return t.Format(time.RFC3339)
+	}
+	// the sections are separated by lines without cells, so that their columns are aligned separately
+	fmt.Fprintf(tw, "From\t%s\n", taken(d.From))
+	fmt.Fprintf(tw, "To\t%s\n", taken(d.To))
+	fmt.Fprintf(tw, "\n")
+
+	fmt.Fprintf(tw, "Metric\tFrom\tTo\tChange\n")
+	for _, m := range d.Metrics {
+		delta := formatMetric(m.Delta)
+		if m.Delta > 0 && delta != "0" {
+			delta = "+" + delta
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", m.Name, formatMetric(m.From), formatMetric(m.To), delta)
+	}
+
+	if d.Detailed {
+		fmt.Fprintf(tw, "\n")
+		for _, l := range []struct {
+			name  string
+			names []string
+		}{{"Added", d.Added}, {"Removed", d.Removed}, {"Occupied", d.Occupied}, {"Vacated", d.Vacated}, {"Collapsed", d.Collapsed}} {
+			list := "-"
+			if len(l.names) > 0 {
+				list = strings.Join(l.names, ", ")
+			}
+			fmt.Fprintf(tw, "%s\t%d\t%s\n", l.name, len(l.names), list)
+		}
+	}
+
+	return tw.Flush()
+}
+
+// formatMetric rounds a metric to 3 decimals without trailing zeros
+func formatMetric(v float64) string {
+	v = math.Round(v*1000) / 1000
+	if v == 0 {
+		// no negative zero
+		v = 0
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,120 @@
+package burrows
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiffReports(t *testing.T) {
+
+	before := Snapshot{Taken: testReport.Taken, Burrows: testReportBurrows}
+
+	var after Snapshot
+	after.Taken = before.Taken.Add(7 * 24 * time.Hour)
+	for _, b := range testReportBurrows {
+		switch b.Name {
+		case "A":
+			continue
+		case "B":
+			b.Occupants = []string{"b"}
+		case "C":
+			b.Occupants = nil
+		case "D":
+			b.AgeInMin = maxAgeInMin
+		}
+		after.Burrows = append(after.Burrows, b)
+	}
+	after.Burrows = append(after.Burrows, Burrow{Name: "F", Depth: 2}, Burrow{Name: "G", Removed: true})
+
+	d := DiffReports(NewSnapshotReport(before, Selector{}), NewSnapshotReport(after, Selector{}))
+
+	if !d.Detailed || !d.From.Equal(before.Taken) || !d.To.Equal(after.Taken) {
+		t.Fatalf("wrong diff. got: %+v", d)
+	}
+	for _, l := range []struct {
+		name          string
+		got, expected []string
+	}{
+		{"added", d.Added, []string{"F"}},
+		{"removed", d.Removed, []string{"A"}},
+		{"occupied", d.Occupied, []string{"B"}},
+		{"vacated", d.Vacated, []string{"C"}},
+		{"collapsed", d.Collapsed, []string{"D"}},
+	} {
+		if !slices.Equal(l.got, l.expected) {
+			t.Errorf("wrong %s burrows. expected: %v, got: %v", l.name, l.expected, l.got)
+		}
+	}
+
+	metrics := make(map[string]MetricChange)
+	for _, m := range d.Metrics {
+		metrics[m.Name] = m
+	}
+	if m := metrics["totalDepth"]; m.From != testReport.TotalDepth || m.Delta < 1.69 || m.Delta > 1.71 {
+		t.Errorf("wrong change of the total depth. got: %+v", m)
+	}
+	// B lost a slot and D its 3 slots, F has one
+	if m := metrics["freeSlots"]; m.From != 7 || m.To != 4 || m.Delta != -3 {
+		t.Errorf("wrong change of the free slots. got: %+v", m)
+	}
+
+	var buf bytes.Buffer
+	if err := d.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"freeSlots      7      4      -3", "totalDepth     31.8   33.5   +1.7", "Collapsed  1  D"} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("the text should contain %q. got:\n%s", line, buf.String())
+		}
+	}
+
+	// decoded reports don't have their burrows
+	d = DiffReports(testReport, NewSnapshotReport(after, Selector{}))
+	if d.Detailed || len(d.Added) != 0 || len(d.Metrics) == 0 {
+		t.Errorf("reports without burrows should only be compared by their metrics. got: %+v", d)
+	}
+}
+
+func TestReadDiffReport(t *testing.T) {
+
+	var report bytes.Buffer
+	if err := json.NewEncoder(&report).Encode(testReport); err != nil {
+		t.Fatal(err)
+	}
+	r, err := ReadDiffReport(bytes.NewReader(report.Bytes()), FormatJSON, Selector{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Burrows != nil || r.TotalDepth != testReport.TotalDepth || !r.Taken.Equal(testReport.Taken) {
+		t.Errorf("wrong report. got: %+v", r)
+	}
+	sel, _ := ParseSelector("site=north")
+	if _, err := ReadDiffReport(bytes.NewReader(report.Bytes()), FormatJSON, sel); err == nil {
+		t.Errorf("reports should not be filtered by labels")
+	}
+
+	var dump bytes.Buffer
+	burrows := []Burrow{{Name: "A", Labels: map[string]string{"site": "north"}}, {Name: "B"}}
+	if err := WriteSnapshot(&dump, Snapshot{Taken: testReport.Taken, Burrows: burrows}); err != nil {
+		t.Fatal(err)
+	}
+	r, err = ReadDiffReport(&dump, FormatJSON, sel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Burrows) != 1 || r.Count != 1 || !r.Taken.Equal(testReport.Taken) {
+		t.Errorf("the dump should be summarized. got: %+v", r)
+	}
+
+	r, err = ReadDiffReport(strings.NewReader(`{"name":"A"}`+"\n"+`{"name":"B","removed":true}`+"\n"), FormatNDJSON, Selector{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Burrows) != 1 || !r.Taken.IsZero() {
+		t.Errorf("the data file should be summarized without its removed burrows. got: %+v", r)
+	}
+}
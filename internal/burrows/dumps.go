@@ -0,0 +1,172 @@
+package burrows
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrNoDump is returned when the dump directory has no valid dump.
+var ErrNoDump = errors.New("no valid dump")
+
+// dumpTimeFormat is part of the name of a dump. It sorts in the same order as the time
+const dumpTimeFormat = "20060102T150405.000000000Z"
+
+// Dumps are the snapshots of the burrows kept in a directory.
+// Every dump is named after the time it was taken, ex: `dump_20240301T100000.000000000Z.json`.
+type Dumps struct {
+	Dir string
+	// Keep is the number of dumps to keep. 0 keeps all of them
+	Keep int
+	// MaxAge is how long a dump is kept. 0 keeps them forever
+	MaxAge time.Duration
+}
+
+// Dump is a snapshot file in the dump directory.
+type Dump struct {
+	Path  string
+	Taken time.Time
+}
+
+// Write stores the snapshot atomically, a crash leaves either a complete dump or none behind.
+// It returns the path of the new dump.
+func (d Dumps) Write(s Snapshot) (string, error) {
+	path := filepath.Join(d.Dir, "dump_"+s.Taken.UTC().Format(dumpTimeFormat)+".json")
+	return path, WriteSnapshotFile(path, s)
+}
+
+// List returns the dumps in the directory, the newest first.
+// Dumps of older versions, which are not named after the time they were taken, are dated by their modification time.
+func (d Dumps) List() ([]Dump, error) {
+	entries, err := os.ReadDir(d.Dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var dumps []Dump
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, "dump_") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		taken, err := time.Parse(dumpTimeFormat, strings.TrimSuffix(strings.TrimPrefix(name, "dump_"), ".json"))
+		if err != nil {
+			info, err := e.Info()
+			if err != nil {
+				continue
+			}
+			taken = info.ModTime()
+		}
+		dumps = append(dumps, Dump{Path: filepath.Join(d.Dir, name), Taken: taken})
+	}
+	slices.SortFunc(dumps, func(a, b Dump) int { return b.Taken.Compare(a.Taken) })
+
+	return dumps, nil
+}
+
+// Read decodes the dump and verifies its checksum.
+func (d Dump) Read() (Snapshot, error) {
+	f, err := os.Open(d.Path)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	defer f.Close()
+
+	return ReadSnapshot(f)
+}
+
+// Latest returns the newest dump that can be read and matches its checksum.
+// Dumps that are newer but corrupt are passed to `skip` before falling back to older ones.
+// It returns ErrNoDump if there is no valid dump.
+func (d Dumps) Latest(skip func(dump Dump, err error)) (Dump, Snapshot, error) {
+	dumps, err := d.List()
+	if err != nil {
+		return Dump{}, Snapshot{}, err
+	}
+
+	for _, dump := range dumps {
+		snapshot, err := dump.Read()
+		if err != nil {
+			if skip != nil {
+				skip(dump, err)
+			}
+			continue
+		}
+		return dump, snapshot, nil
+	}
+
+	return Dump{}, Snapshot{}, ErrNoDump
+}
+
+// At returns the newest dump taken at or before `t`, ex: to see the burrows as they were at the time.
+// It returns ErrNoDump if all the dumps are newer.
+func (d Dumps) At(t time.Time) (Dump, error) {
+	dumps, err := d.List()
+	if err != nil {
+		return Dump{}, err
+	}
+	for _, dump := range dumps {
+		if !dump.Taken.After(t) {
+			return dump, nil
+		}
+	}
+	return Dump{}, ErrNoDump
+}
+
+// Prune removes the dumps that are not retained anymore and returns their paths.
+// The newest dump is always kept.
+func (d Dumps) Prune(now time.Time) ([]string, error) {
+	dumps, err := d.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	for i, dump := range dumps {
+		if i == 0 {
+			continue
+		}
+		tooMany := d.Keep > 0 && i >= d.Keep
+		tooOld := d.MaxAge > 0 && now.Sub(dump.Taken) > d.MaxAge
+		if !tooMany && !tooOld {
+			continue
+		}
+		if err := os.Remove(dump.Path); err != nil {
+			return removed, err
+		}
+		removed = append(removed, dump.Path)
+	}
+
+	return removed, nil
+}
+
+// writeFileAtomic replaces the file with what `write` produces.
+// The data is written to a temporary file in the same directory which is then renamed.
+func writeFileAtomic(path string, write func(w io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if err := write(tmp); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,191 @@
+package burrows
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestDumpsPrune(t *testing.T) {
+
+	start := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	scenarios := []struct {
+		name     string
+		keep     int
+		maxAge   time.Duration
+		expected int
+	}{
+		{name: "keep all", expected: 5},
+		{name: "by count", keep: 2, expected: 2},
+		{name: "by age", maxAge: 90 * time.Minute, expected: 2},
+		{name: "newest is never too old", maxAge: time.Minute, expected: 1},
+		{name: "by count and age", keep: 3, maxAge: 150 * time.Minute, expected: 3},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			t.Parallel()
+
+			dumps := Dumps{Dir: t.TempDir(), Keep: s.keep, MaxAge: s.maxAge}
+			for i := range 5 {
+				if _, err := dumps.Write(Snapshot{Taken: start.Add(time.Duration(i) * time.Hour)}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if _, err := dumps.Prune(start.Add(4 * time.Hour)); err != nil {
+				t.Fatal(err)
+			}
+
+			left, err := dumps.List()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(left) != s.expected {
+				t.Fatalf("wrong number of dumps left. expected: %d, got: %v", s.expected, left)
+			}
+			if !left[0].Taken.Equal(start.Add(4 * time.Hour)) {
+				t.Errorf("the newest dump should be kept. got: %v", left)
+			}
+		})
+	}
+}
+
+func TestDumpsList(t *testing.T) {
+
+	dumps := Dumps{Dir: t.TempDir()}
+
+	taken := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	newest, err := dumps.Write(Snapshot{Taken: taken, Burrows: []Burrow{{Name: "one"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// dumps of older versions are dated by their modification time
+	legacy := filepath.Join(dumps.Dir, "dump_123456.json")
+	if err := os.WriteFile(legacy, []byte(`[]`), 0o664); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(legacy, taken.Add(-time.Hour), taken.Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	// other files are ignored
+	if err := os.WriteFile(filepath.Join(dumps.Dir, "burrows.db"), nil, 0o664); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := dumps.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].Path != newest || list[1].Path != legacy {
+		t.Fatalf("wrong dumps. expected %s and %s, got: %v", newest, legacy, list)
+	}
+
+	f, err := os.Open(newest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	snap, err := ReadSnapshot(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !snap.Taken.Equal(taken) || len(snap.Burrows) != 1 || snap.Checksum == "" {
+		t.Errorf("wrong dump content. got: %+v", snap)
+	}
+
+	for at, expected := range map[time.Time]string{taken: newest, taken.Add(time.Hour): newest, taken.Add(-time.Minute): legacy} {
+		if dump, err := dumps.At(at); err != nil || dump.Path != expected {
+			t.Errorf("wrong dump at %s. expected: %s, got: %s %v", at, expected, dump.Path, err)
+		}
+	}
+	if _, err := dumps.At(taken.Add(-2 * time.Hour)); !errors.Is(err, ErrNoDump) {
+		t.Errorf("there should be no dump before the oldest. got: %v", err)
+	}
+}
+
+func TestDumpsLatest(t *testing.T) {
+
+	dumps := Dumps{Dir: t.TempDir()}
+
+	if _, _, err := dumps.Latest(nil); !errors.Is(err, ErrNoDump) {
+		t.Fatalf("expected: %v, got: %v", ErrNoDump, err)
+	}
+
+	taken := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	valid, err := dumps.Write(Snapshot{Taken: taken, Burrows: []Burrow{{Name: "one"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	truncated, err := dumps.Write(Snapshot{Taken: taken.Add(time.Hour), Burrows: []Burrow{{Name: "one"}, {Name: "two"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(truncated, 40); err != nil {
+		t.Fatal(err)
+	}
+	tampered, err := dumps.Write(Snapshot{Taken: taken.Add(2 * time.Hour), Burrows: []Burrow{{Name: "one", Depth: 1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(tampered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(tampered, bytes.Replace(b, []byte(`"depth":1`), []byte(`"depth":9`), 1), 0o664); err != nil {
+		t.Fatal(err)
+	}
+
+	var skipped []string
+	dump, snap, err := dumps.Latest(func(d Dump, _ error) { skipped = append(skipped, d.Path) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if dump.Path != valid || !snap.Taken.Equal(taken) || len(snap.Burrows) != 1 {
+		t.Errorf("expected the newest valid dump %s, got: %s %+v", valid, dump.Path, snap)
+	}
+	if !slices.Equal(skipped, []string{tampered, truncated}) {
+		t.Errorf("expected the corrupt dumps to be skipped, newest first. got: %v", skipped)
+	}
+}
+
+func TestManagerSnapshots(t *testing.T) {
+
+	clock := NewFakeClock(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC))
+	dir := t.TempDir()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m := NewManager(ctx, slog.New(slog.NewTextHandler(io.Discard, nil)),
+		WithClock(clock), WithDumpDir(dir), WithSnapshots(time.Hour), WithRetention(2, 0))
+	loadBurrows(m, Burrow{Name: "one", Depth: 1})
+
+	clock.Advance(3*time.Hour + time.Minute)
+
+	// the final dump is written on shutdown
+	cancel()
+	<-m.Done
+
+	list, err := Dumps{Dir: dir}.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("expected the final dump and the last periodic dump. got: %v", list)
+	}
+	if !list[0].Taken.Equal(clock.Now()) {
+		t.Errorf("the newest dump should be taken on shutdown at %v. got: %v", clock.Now(), list[0].Taken)
+	}
+	if list[1].Taken.After(clock.Now().Add(-time.Minute)) {
+		t.Errorf("the other dump should be a periodic one, taken on the hour. got: %v", list[1].Taken)
+	}
+}
@@ -0,0 +1,58 @@
+package burrows
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseDuration works like `time.ParseDuration` but also accepts whole days, ex: `7d` or `2d12h`.
+// Days are the natural unit in the life of a burrow.
+func ParseDuration(s string) (time.Duration, error) {
+	days, rest, found := strings.Cut(s, "d")
+	if !found {
+		return time.ParseDuration(s)
+	}
+
+	n, err := strconv.Atoi(days)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid number of days in duration %q", s)
+	}
+
+	d := time.Duration(n) * 24 * time.Hour
+	if rest == "" {
+		return d, nil
+	}
+
+	r, err := time.ParseDuration(rest)
+	if err != nil {
+		return 0, err
+	}
+	return d + r, nil
+}
+
+// FormatDuration is the inverse of ParseDuration, ex: `2d12h` or `1h30m`. Parts that are zero are left out.
+func FormatDuration(d time.Duration) string {
+	if d < 0 {
+		return "-" + FormatDuration(-d)
+	}
+	days := d / (24 * time.Hour)
+	d -= days * 24 * time.Hour
+
+	s := ""
+	if days > 0 {
+		s = strconv.Itoa(int(days)) + "d"
+		if d == 0 {
+			return s
+		}
+	}
+	rest := d.String()
+	if strings.HasSuffix(rest, "m0s") {
+		rest = strings.TrimSuffix(rest, "0s")
+	}
+	if strings.HasSuffix(rest, "h0m") {
+		rest = strings.TrimSuffix(rest, "0m")
+	}
+	return s + rest
+}
@@ -0,0 +1,68 @@
+package burrows
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+
+	scenarios := []struct {
+		s        string
+		expected time.Duration
+		fails    bool
+	}{
+		{s: "90m", expected: 90 * time.Minute},
+		{s: "7d", expected: 7 * 24 * time.Hour},
+		{s: "2d12h", expected: 60 * time.Hour},
+		{s: "0d", expected: 0},
+		{s: "xd", fails: true},
+		{s: "-1d", fails: true},
+		{s: "1d12", fails: true},
+		{s: "", fails: true},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.s, func(t *testing.T) {
+			t.Parallel()
+
+			d, err := ParseDuration(s.s)
+			if s.fails {
+				if err == nil {
+					t.Errorf("expected an error, got: %v", d)
+				}
+				return
+			}
+
+			if err != nil || d != s.expected {
+				t.Errorf("wrong duration. expected: %v, got: %v, error: %v", s.expected, d, err)
+			}
+		})
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+
+	scenarios := map[time.Duration]string{
+		0:                          "0s",
+		90 * time.Minute:           "1h30m",
+		time.Hour:                  "1h",
+		7 * 24 * time.Hour:         "7d",
+		60 * time.Hour:             "2d12h",
+		24*time.Hour + time.Minute: "1d1m",
+		30 * time.Second:           "30s",
+		-(26 * time.Hour):          "-1d2h",
+	}
+
+	for d, expected := range scenarios {
+		if s := FormatDuration(d); s != expected {
+			t.Errorf("wrong format of %v. expected: %s, got: %s", time.Duration(d), expected, s)
+		}
+		if d < 0 {
+			continue
+		}
+		if parsed, err := ParseDuration(FormatDuration(d)); err != nil || parsed != d {
+			t.Errorf("%s should parse back to %v. got: %v, error: %v", FormatDuration(d), d, parsed, err)
+		}
+	}
+}
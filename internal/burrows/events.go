@@ -0,0 +1,371 @@
+package burrows
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// EventType names a state transition of the burrows.
+type EventType string
+
+const (
+	EventAdded   EventType = "added"
+	EventRented  EventType = "rented"
+	EventVacated EventType = "vacated"
+	EventLabeled EventType = "labeled"
+	// EventAged is the aging of the burrows of a shard since its last event. It is only logged once it adds up
+	// to `agedEventMinutes`, when burrows collapse and before the other events of the shard
+	EventAged      EventType = "aged"
+	EventCollapsed EventType = "collapsed"
+	// EventUpdated is the reconciliation of a burrow with a newer record of the inventory
+	EventUpdated EventType = "updated"
+	// EventRemoved is the decommissioning of a burrow. Its gophers are the occupants that lost their home
+	EventRemoved EventType = "removed"
+	// EventAborted cancels the previous event of its shard: a change that was logged but not saved, so it was rejected
+	EventAborted EventType = "aborted"
+	// EventCheckpoint marks the end of the events of a shard that are part of a snapshot
+	EventCheckpoint EventType = "checkpoint"
+	// EventSnapshot starts a log that was truncated once a snapshot was written to a dump.
+	// The burrows of the dump come first, the events that follow happened after the snapshot
+	EventSnapshot EventType = "snapshot"
+)
+
+// agedEventMinutes is how much the burrows of a shard age before the aging is logged
+const agedEventMinutes = 60
+
+// Event is a state transition of the burrows.
+// Shards append their events to the log before they acknowledge a change, so replaying
+// the log in order rebuilds the burrows as they were when the process stopped.
+type Event struct {
+	Seq  uint64    `json:"seq"`
+	At   time.Time `json:"at"`
+	Type EventType `json:"type"`
+	// Shard that owns the burrow. The burrows of a shard age together
+	Shard int `json:"shard"`
+	// Burrow is the state of the burrow after the transition. Aging events have none
+	Burrow *Burrow `json:"burrow,omitempty"`
+	// Gophers that moved in or out, or lost their home
+	Gophers []string `json:"gophers,omitempty"`
+	// Minutes the burrows of the shard aged on every tick, and the number of ticks. No ticks is a single one
+	Minutes int `json:"minutes,omitempty"`
+	Ticks   int `json:"ticks,omitempty"`
+	// Dump of a snapshot event and the number of its burrows that belong to every shard
+	Dump  string `json:"dump,omitempty"`
+	Sizes []int  `json:"sizes,omitempty"`
+}
+
+// EventLog is a durable, append only list of events.
+type EventLog interface {
+	// Append writes the events in order and only returns once they are durable.
+	Append(events ...Event) error
+	// Truncate drops the events that are part of the snapshot written to the dump: the events of every shard
+	// up to its last checkpoint. The log then starts with an EventSnapshot of the dump.
+	// `sizes` is the number of burrows of every shard in the dump.
+	Truncate(dump Dump, sizes []int) error
+	Close() error
+}
+
+// NopEventLog is used when the events should not be recorded
+var NopEventLog EventLog = nopEventLog{}
+
+type nopEventLog struct{}
+
+func (nopEventLog) Append(_ ...Event) error { return nil }
+
+func (nopEventLog) Truncate(_ Dump, _ []int) error { return nil }
+
+func (nopEventLog) Close() error { return nil }
+
+// FileEventLog appends the events to a file, one JSON object per line.
+type FileEventLog struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+	seq  uint64
+}
+
+// NewFileEventLog opens the log for appending, creating the file if needed.
+// The sequence numbers continue after the events of an existing log.
+func NewFileEventLog(path string) (*FileEventLog, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o664)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := io.ReadAll(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	// drop the half written event of a crash, new events must start on a new line
+	if end := bytes.LastIndexByte(b, '\n') + 1; end < len(b) {
+		if err := f.Truncate(int64(end)); err != nil {
+			f.Close()
+			return nil, err
+		}
+		b = b[:end]
+	}
+
+	events, err := ReadEvents(bytes.NewReader(b))
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	l := &FileEventLog{path: path, f: f}
+	for _, e := range events {
+		// a truncated log keeps the events of every shard in order, not all of them
+		l.seq = max(l.seq, e.Seq)
+	}
+
+	return l, nil
+}
+
+func (l *FileEventLog) Append(events ...Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var buf []byte
+	seq := l.seq
+	for _, e := range events {
+		seq++
+		e.Seq = seq
+		b, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, b...), '\n')
+	}
+
+	if _, err := l.f.Write(buf); err != nil {
+		return err
+	}
+	if err := l.f.Sync(); err != nil {
+		return err
+	}
+	l.seq = seq
+
+	return nil
+}
+
+func (l *FileEventLog) Truncate(dump Dump, sizes []int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, err := l.f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	events, err := ReadEvents(l.f)
+	if err != nil {
+		return err
+	}
+
+	kept := append([]Event{{At: dump.Taken, Type: EventSnapshot, Dump: dump.Path, Sizes: sizes}}, afterCheckpoints(events, len(sizes))...)
+	err = writeFileAtomic(l.path, func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		for _, e := range kept {
+			if err := enc.Encode(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return l.reopen(l.path)
+}
+
+// Rename moves the log, ex: once a new log replaces the old one.
+func (l *FileEventLog) Rename(path string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := os.Rename(l.path, path); err != nil {
+		return err
+	}
+	l.path = path
+	return nil
+}
+
+// reopen appends to the file at path from now on
+func (l *FileEventLog) reopen(path string) error {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND, 0o664)
+	if err != nil {
+		return err
+	}
+	l.f.Close()
+	l.f = f
+	return nil
+}
+
+func (l *FileEventLog) Close() error { return l.f.Close() }
+
+// afterCheckpoints returns the events that follow the last checkpoint of their shard.
+// The events of the shards that did not exist yet are all kept.
+func afterCheckpoints(events []Event, shards int) []Event {
+	checkpoints := make([]uint64, shards)
+	for _, e := range events {
+		if e.Type == EventCheckpoint && e.Shard < shards {
+			checkpoints[e.Shard] = e.Seq
+		}
+	}
+
+	var kept []Event
+	for _, e := range events {
+		if e.Type == EventSnapshot || e.Type == EventCheckpoint {
+			continue
+		}
+		if e.Shard < shards && e.Seq <= checkpoints[e.Shard] {
+			continue
+		}
+		kept = append(kept, e)
+	}
+	return kept
+}
+
+// ReadEvents decodes a log.
+// A crash can leave the last event half written. It is ignored, as it was never acknowledged.
+func ReadEvents(r io.Reader) ([]Event, error) {
+	var events []Event
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	var broken error
+	for sc.Scan() {
+		if broken != nil {
+			// only the last line may be broken
+			return nil, broken
+		}
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var e Event
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			broken = errors.Join(errors.New("corrupt event log"), err)
+			continue
+		}
+		events = append(events, e)
+	}
+
+	return events, sc.Err()
+}
+
+// Replay rebuilds the burrows from the events, in the order in which the manager would list them.
+// A truncated log starts with the snapshot of a dump, `load` reads it, ex: `Dump.Read`.
+func Replay(events []Event, load func(Dump) (Snapshot, error)) ([]Burrow, error) {
+	var shards [][]Burrow
+	// where is the position of every burrow in its shard
+	type position struct{ shard, i int }
+	where := make(map[string]position)
+
+	// aborted are the indexes of the events that were cancelled, the last ones of their shard before an EventAborted
+	aborted := make(map[int]bool)
+	last := make(map[int]int)
+	for i, e := range events {
+		switch e.Type {
+		case EventAborted:
+			if j, ok := last[e.Shard]; ok {
+				aborted[j] = true
+				delete(last, e.Shard)
+			}
+		case EventSnapshot:
+		default:
+			last[e.Shard] = i
+		}
+	}
+
+	for i, e := range events {
+		if aborted[i] {
+			continue
+		}
+		switch e.Type {
+		case EventSnapshot:
+			if load == nil {
+				return nil, fmt.Errorf("the event log starts from the dump %s", e.Dump)
+			}
+			snapshot, err := load(Dump{Path: e.Dump, Taken: e.At})
+			if err != nil {
+				return nil, fmt.Errorf("dump of the event log: %w", err)
+			}
+			shards = nil
+			clear(where)
+			rest := snapshot.Burrows
+			for i, size := range e.Sizes {
+				if size > len(rest) {
+					return nil, fmt.Errorf("the dump %s does not match the event log", e.Dump)
+				}
+				shards = append(shards, slices.Clone(rest[:size]))
+				rest = rest[size:]
+				for j, b := range shards[i] {
+					where[b.Name] = position{shard: i, i: j}
+				}
+			}
+			if len(rest) > 0 {
+				return nil, fmt.Errorf("the dump %s does not match the event log", e.Dump)
+			}
+		case EventAdded:
+			for len(shards) <= e.Shard {
+				shards = append(shards, nil)
+			}
+			where[e.Burrow.Name] = position{shard: e.Shard, i: len(shards[e.Shard])}
+			shards[e.Shard] = append(shards[e.Shard], *e.Burrow)
+		case EventRented, EventVacated, EventLabeled, EventUpdated:
+			if p, ok := where[e.Burrow.Name]; ok {
+				shards[p.shard][p.i] = *e.Burrow
+			}
+		case EventRemoved:
+			p, ok := where[e.Burrow.Name]
+			if !ok {
+				continue
+			}
+			delete(where, e.Burrow.Name)
+			shards[p.shard] = slices.Delete(shards[p.shard], p.i, p.i+1)
+			for _, b := range shards[p.shard][p.i:] {
+				where[b.Name] = position{shard: p.shard, i: where[b.Name].i - 1}
+			}
+		case EventAged:
+			if e.Shard < len(shards) {
+				for range max(e.Ticks, 1) {
+					ageBurrows(shards[e.Shard], e.Minutes)
+				}
+			}
+		}
+	}
+
+	var burrows []Burrow
+	for _, sh := range shards {
+		burrows = append(burrows, sh...)
+	}
+	return burrows, nil
+}
+
+// ageBurrows ages the burrows by the given minutes and returns the ones that collapsed meanwhile.
+// Shards and the replay of the event log share it, so that both end up with the same depths.
+func ageBurrows(burrows []Burrow, minutes int) []Burrow {
+	var collapsed []Burrow
+	for i := range burrows {
+		standing := !burrows[i].IsCollapsed()
+		if minutes == 1 {
+			burrows[i].IncrementAge()
+		} else {
+			burrows[i].AgeBy(minutes)
+		}
+		if standing && burrows[i].IsCollapsed() {
+			collapsed = append(collapsed, burrows[i])
+		}
+	}
+	return collapsed
+}
@@ -0,0 +1,331 @@
+package burrows
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReadEvents(t *testing.T) {
+
+	scenarios := []struct {
+		name  string
+		data  string
+		count int
+		fails bool
+	}{
+		{name: "empty"},
+		{name: "complete", data: "{\"seq\":1,\"type\":\"aged\"}\n{\"seq\":2,\"type\":\"aged\"}\n", count: 2},
+		{name: "torn last event", data: "{\"seq\":1,\"type\":\"aged\"}\n{\"seq\":2,\"ty", count: 1},
+		{name: "corrupt event in the middle", data: "{\"seq\":1,\"ty\n{\"seq\":2,\"type\":\"aged\"}\n", fails: true},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			t.Parallel()
+
+			events, err := ReadEvents(strings.NewReader(s.data))
+			if s.fails {
+				if err == nil {
+					t.Errorf("corrupt log should fail. got: %v", events)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(events) != s.count {
+				t.Errorf("wrong number of events. expected: %d, got: %v", s.count, events)
+			}
+		})
+	}
+}
+
+func TestFileEventLogReopen(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "events.log")
+	if err := os.WriteFile(path, []byte("{\"seq\":1,\"type\":\"aged\",\"minutes\":1}\n{\"seq\":2,\"ty"), 0o664); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := NewFileEventLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Append(Event{Type: EventAged, Minutes: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	events, err := ReadEvents(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 2 || events[1].Seq != 2 || events[1].Minutes != 2 {
+		t.Errorf("the torn event should be replaced by the new one. got: %+v", events)
+	}
+}
+
+func TestReplay(t *testing.T) {
+
+	clock := NewFakeClock(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC))
+	path := filepath.Join(t.TempDir(), "events.log")
+	l, err := NewFileEventLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// no dumps, the log is not truncated when the manager stops
+	ctx, stop := context.WithCancel(context.Background())
+	m := NewManager(ctx, slog.New(slog.NewTextHandler(io.Discard, nil)), WithClock(clock), WithTact(time.Minute), WithDumpDir(""), WithEventLog(l))
+	// more than one shard, the last one about to collapse
+	data := generateBurrows(shardSize + 10)
+	data[len(data)-1].AgeInMin = maxAgeInMin - 30
+	loadBurrows(m, data...)
+
+	clock.Advance(3 * time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	family, err := m.Rentout(ctx, Rental{Gophers: []string{"Gus", "Goldie"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Minute)
+	if _, err := m.SetLabels(data[len(data)-1].Name, map[string]string{"site": "north"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Advance(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Vacate(family.Name, []string{"Goldie"}); err != nil {
+		t.Fatal(err)
+	}
+	m.Reconcile([]Burrow{
+		{Name: data[1].Name, Capacity: 8, Width: 2},
+		{Name: data[0].Name, Removed: true},
+		{Name: "new burrow", Capacity: 2},
+	})
+	clock.Advance(2 * time.Minute)
+
+	// the aging since the last events is logged when the manager stops
+	expected := m.CurrentStatus()
+	stop()
+	<-m.Done
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	events, err := ReadEvents(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Replay(events, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("the replay should rebuild the burrows of the manager. expected %d burrows, got: %d", len(expected), len(got))
+	}
+
+	collapsed, aged := 0, 0
+	for _, e := range events {
+		switch e.Type {
+		case EventCollapsed:
+			collapsed++
+		case EventAged:
+			aged++
+		}
+	}
+	if collapsed != 1 {
+		t.Errorf("expected one burrow to collapse, got: %d", collapsed)
+	}
+	// the aging is logged with the collapse and the other events, not on every tick
+	if aged > 10 {
+		t.Errorf("the aging should be logged in batches. got %d aging events", aged)
+	}
+}
+
+// failingStore refuses the changes while it is broken
+type failingStore struct {
+	Store
+	broken atomic.Bool
+}
+
+func (s *failingStore) Save(at time.Time, burrows ...Burrow) error {
+	if s.broken.Load() {
+		return errors.New("disk full")
+	}
+	return s.Store.Save(at, burrows...)
+}
+
+func (s *failingStore) Delete(names ...string) error {
+	if s.broken.Load() {
+		return errors.New("disk full")
+	}
+	return s.Store.Delete(names...)
+}
+
+func TestReplayAborted(t *testing.T) {
+
+	clock := NewFakeClock(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC))
+	path := filepath.Join(t.TempDir(), "events.log")
+	l, err := NewFileEventLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	store := &failingStore{Store: nopStore{}}
+
+	ctx, stop := context.WithCancel(context.Background())
+	m := NewManager(ctx, slog.New(slog.NewTextHandler(io.Discard, nil)), WithClock(clock), WithTact(time.Minute), WithDumpDir(""), WithEventLog(l), WithStore(store))
+	loadBurrows(m, Burrow{Name: "one", Capacity: 2}, Burrow{Name: "two", Capacity: 2})
+	clock.Advance(time.Minute)
+
+	rctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	home, err := m.Rentout(rctx, Rental{Gophers: []string{"Gus"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the store refuses the changes, they are rejected and should not come back with the replay
+	store.broken.Store(true)
+	if _, err := m.SetLabels("one", map[string]string{"site": "north"}); err == nil {
+		t.Fatal("the labels should not be changed when the store fails")
+	}
+	if _, err := m.Rentout(rctx, Rental{Gophers: []string{"Goldie"}}); err == nil {
+		t.Fatal("the rental should fail when the store fails")
+	}
+	m.Reconcile([]Burrow{{Name: "two", Removed: true}})
+	store.broken.Store(false)
+
+	clock.Advance(time.Minute)
+	if _, err := m.Vacate(home.Name, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := m.CurrentStatus()
+	stop()
+	<-m.Done
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	events, err := ReadEvents(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aborted := 0
+	for _, e := range events {
+		if e.Type == EventAborted {
+			aborted++
+		}
+	}
+	if aborted != 3 {
+		t.Errorf("the rejected changes should be aborted in the log. expected: 3, got: %d", aborted)
+	}
+
+	got, err := Replay(events, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("the replay should ignore the aborted changes.\nexpected: %+v\ngot:      %+v", expected, got)
+	}
+}
+
+func TestEventLogTruncate(t *testing.T) {
+
+	clock := NewFakeClock(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC))
+	dir := t.TempDir()
+	path := filepath.Join(dir, "events.log")
+	l, err := NewFileEventLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	m := newTestManager(t, clock, WithEventLog(l), WithDumpDir(dir), WithSnapshots(10*time.Minute))
+	data := generateBurrows(shardSize + 10)
+	loadBurrows(m, data...)
+	if _, err := m.Rentout(context.Background(), Rental{Gophers: []string{"Gus"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	readLog := func() []Event {
+		t.Helper()
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		events, err := ReadEvents(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return events
+	}
+
+	// the dump is written in the background
+	clock.Advance(10 * time.Minute)
+	deadline := time.Now().Add(5 * time.Second)
+	for events := readLog(); len(events) == 0 || events[0].Type != EventSnapshot; events = readLog() {
+		if time.Now().After(deadline) {
+			t.Fatalf("the event log was not truncated. got %d events", len(events))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// changes after the snapshot are replayed on top of the dump
+	if _, err := m.Rentout(context.Background(), Rental{Gophers: []string{"Goldie", "Gil"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.SetLabels(data[len(data)-1].Name, map[string]string{"site": "north"}); err != nil {
+		t.Fatal(err)
+	}
+	m.Reconcile([]Burrow{{Name: data[0].Name, Removed: true}, {Name: "new burrow", Capacity: 2}})
+	expected := m.CurrentStatus()
+
+	events := readLog()
+	if events[0].Dump == "" || !slices.Equal(events[0].Sizes, []int{shardSize, 10}) {
+		t.Errorf("the log should start from the dump. got: %+v", events[0])
+	}
+	if len(events) > 10 {
+		t.Errorf("the events of the snapshot should be dropped. got %d events", len(events))
+	}
+
+	got, err := Replay(events, Dump.Read)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("the replay should rebuild the burrows of the manager. expected %d burrows, got: %d", len(expected), len(got))
+	}
+
+	if _, err := Replay(events, nil); err == nil {
+		t.Error("a truncated log can not be replayed without its dump")
+	}
+}
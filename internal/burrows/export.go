@@ -0,0 +1,201 @@
+package burrows
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// FormatText is an aligned table, only used to export burrows
+const FormatText Format = "text"
+
+// ParseExportFormat returns the format of exports with the given name: the formats of data files and text.
+func ParseExportFormat(name string) (Format, error) {
+	if strings.EqualFold(name, string(FormatText)) {
+		return FormatText, nil
+	}
+	return ParseFormat(name)
+}
+
+// ExportRecord is a burrow of the inventory together with the fields computed from its state.
+type ExportRecord struct {
+	Burrow
+	Status    string  `json:"status"`
+	FreeSlots int     `json:"freeSlots"`
+	Volume    float64 `json:"volume"`
+	// Tenant are the occupants of the burrow, separated by `;`
+	Tenant string `json:"tenant"`
+	// RemainingMin is the remaining lifetime of the burrow in minutes
+	RemainingMin int       `json:"remainingMin"`
+	CollapsesAt  time.Time `json:"collapsesAt"`
+}
+
+// Status of a burrow in the inventory
+const (
+	StatusAvailable = "available"
+	StatusFull      = "full"
+	StatusCollapsed = "collapsed"
+)
+
+// NewExportRecord computes the fields of the burrow at the time `now`. One minute in the life of a burrow lasts one `tact`.
+func NewExportRecord(b Burrow, now time.Time, tact time.Duration) ExportRecord {
+	r := ExportRecord{
+		Burrow:       b,
+		Status:       StatusAvailable,
+		FreeSlots:    b.FreeSlots(),
+		Volume:       b.Volume(),
+		Tenant:       strings.Join(b.Occupants, ";"),
+		RemainingMin: max(maxAgeInMin-b.AgeInMin, 0),
+	}
+	switch {
+	case b.IsCollapsed():
+		r.Status = StatusCollapsed
+	case r.FreeSlots == 0:
+		r.Status = StatusFull
+	}
+	r.CollapsesAt = now.Add(time.Duration(maxAgeInMin-b.AgeInMin) * tact)
+	return r
+}
+
+// Exporter writes the records of the inventory one by one.
+type Exporter interface {
+	Write(r ExportRecord) error
+	// Flush completes the output, it is called once after the last record
+	Flush() error
+}
+
+// NewExporter writes the inventory as JSON, NDJSON, CSV or an aligned text table.
+// Records are written as they come, the inventory never has to fit into memory.
+func NewExporter(w io.Writer, format Format) (Exporter, error) {
+	switch format {
+	case FormatJSON:
+		return &jsonExporter{w: w}, nil
+	case FormatNDJSON:
+		return &ndjsonExporter{enc: json.NewEncoder(w)}, nil
+	case FormatCSV:
+		return &tableExporter{t: csvTable{csv.NewWriter(w)}, header: exportColumns, precision: -1}, nil
+	case FormatText:
+		return &tableExporter{t: textTable{tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)}, header: exportTextHeader, precision: 3}, nil
+	default:
+		return nil, fmt.Errorf("burrows can not be exported as %s", format)
+	}
+}
+
+type jsonExporter struct {
+	w       io.Writer
+	written int
+}
+
+func (e *jsonExporter) Write(r ExportRecord) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	sep := ",\n"
+	if e.written == 0 {
+		sep = "[\n"
+	}
+	e.written++
+	_, err = fmt.Fprintf(e.w, "%s%s", sep, b)
+	return err
+}
+
+func (e *jsonExporter) Flush() error {
+	if e.written == 0 {
+		_, err := io.WriteString(e.w, "[]\n")
+		return err
+	}
+	_, err := io.WriteString(e.w, "\n]\n")
+	return err
+}
+
+type ndjsonExporter struct {
+	enc *json.Encoder
+}
+
+func (e *ndjsonExporter) Write(r ExportRecord) error { return e.enc.Encode(r) }
+
+func (e *ndjsonExporter) Flush() error { return nil }
+
+// exportColumns are the columns of a CSV export. The file can be loaded as data file again
+var exportColumns = []string{"name", "status", "capacity", "tenant", "free_slots", "depth", "width", "volume", "age", "remaining_min", "collapses_at", "labels"}
+
+var exportTextHeader = []string{"NAME", "STATUS", "CAPACITY", "TENANT", "FREE", "DEPTH", "WIDTH", "VOLUME", "AGE", "REMAINING", "COLLAPSES AT", "LABELS"}
+
+// table writes rows of fields
+type table interface {
+	row(fields []string) error
+	flush() error
+}
+
+type tableExporter struct {
+	t      table
+	header []string
+	// precision is the number of decimals of the measures, -1 keeps all of them
+	precision int
+	started   bool
+}
+
+func (e *tableExporter) Write(r ExportRecord) error {
+	if !e.started {
+		e.started = true
+		if err := e.t.row(e.header); err != nil {
+			return err
+		}
+	}
+
+	labels := make([]string, 0, len(r.Labels))
+	for k, v := range r.Labels {
+		labels = append(labels, k+"="+v)
+	}
+	sort.Strings(labels)
+
+	return e.t.row([]string{
+		r.Name,
+		r.Status,
+		strconv.Itoa(r.Slots()),
+		r.Tenant,
+		strconv.Itoa(r.FreeSlots),
+		strconv.FormatFloat(r.Depth, 'f', e.precision, 64),
+		strconv.FormatFloat(r.Width, 'f', e.precision, 64),
+		strconv.FormatFloat(r.Volume, 'f', e.precision, 64),
+		strconv.Itoa(r.AgeInMin),
+		strconv.Itoa(r.RemainingMin),
+		r.CollapsesAt.Format(time.RFC3339),
+		strings.Join(labels, ";"),
+	})
+}
+
+func (e *tableExporter) Flush() error {
+	if !e.started {
+		e.started = true
+		if err := e.t.row(e.header); err != nil {
+			return err
+		}
+	}
+	return e.t.flush()
+}
+
+type csvTable struct{ w *csv.Writer }
+
+func (t csvTable) row(fields []string) error { return t.w.Write(fields) }
+
+func (t csvTable) flush() error {
+	t.w.Flush()
+	return t.w.Error()
+}
+
+type textTable struct{ w *tabwriter.Writer }
+
+func (t textTable) row(fields []string) error {
+	_, err := fmt.Fprintln(t.w, strings.Join(fields, "\t")+"\t")
+	return err
+}
+
+func (t textTable) flush() error { return t.w.Flush() }
@@ -0,0 +1,79 @@
+package burrows
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewExportRecord(t *testing.T) {
+
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	scenarios := []struct {
+		b         Burrow
+		status    string
+		remaining int
+		tenant    string
+	}{
+		{b: Burrow{Name: "empty", Capacity: 2, AgeInMin: maxAgeInMin - 60}, status: StatusAvailable, remaining: 60},
+		{b: Burrow{Name: "family", Capacity: 2, Occupants: []string{"Gus", "Goldie"}}, status: StatusFull, remaining: maxAgeInMin, tenant: "Gus;Goldie"},
+		{b: Burrow{Name: "collapsed", Occupants: []string{"Gus"}, AgeInMin: maxAgeInMin}, status: StatusCollapsed, tenant: "Gus"},
+	}
+
+	for _, s := range scenarios {
+		r := NewExportRecord(s.b, now, time.Second)
+
+		if r.Status != s.status || r.RemainingMin != s.remaining || r.Tenant != s.tenant {
+			t.Errorf("wrong record for %s. expected %s, %d min, tenant %q. got: %+v", s.b.Name, s.status, s.remaining, s.tenant, r)
+		}
+		if expected := now.Add(time.Duration(s.remaining) * time.Second); !r.CollapsesAt.Equal(expected) {
+			t.Errorf("%s should collapse at %v, got: %v", s.b.Name, expected, r.CollapsesAt)
+		}
+	}
+}
+
+func TestExportCSVCanBeLoaded(t *testing.T) {
+
+	burrows := []Burrow{
+		{Name: "The Molehole", Capacity: 4, Occupants: []string{"Gus", "Goldie"}, Depth: 3.137451968592147, Width: 1.3, AgeInMin: 50, Labels: map[string]string{"site": "south", "tier": "premium"}},
+		{Name: "Tunnel, of Mystery", Capacity: 1, Depth: 1.8, Width: 1.1, AgeInMin: 30},
+	}
+
+	var buf bytes.Buffer
+	exp, err := NewExporter(&buf, FormatCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range burrows {
+		if err := exp.Write(NewExportRecord(b, time.Now(), time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := exp.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	dec, err := NewDecoder(&buf, FormatCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loaded []Burrow
+	for {
+		b, err := dec.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		loaded = append(loaded, b)
+	}
+
+	if !reflect.DeepEqual(burrows, loaded) {
+		t.Errorf("the export should load as data file.\nexpected: %+v\ngot:      %+v", burrows, loaded)
+	}
+}
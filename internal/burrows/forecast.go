@@ -0,0 +1,111 @@
+package burrows
+
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+// maxForecastPoints limits the size of the availability curve of a forecast
+const maxForecastPoints = 10_000
+
+// Forecast predicts the future of the burrows assuming no new gophers move in.
+type Forecast struct {
+	From         time.Time        `json:"from"`
+	To           time.Time        `json:"to"`
+	Burrows      []BurrowForecast `json:"burrows"`
+	Availability []ForecastPoint  `json:"availability"`
+	// Paused burrows don't age, the forecast is flat: no burrow collapses nor grows deeper
+	Paused bool `json:"paused"`
+}
+
+// BurrowForecast is the predicted future of a single burrow.
+type BurrowForecast struct {
+	Name string `json:"name"`
+	// CollapsesAt is the time the burrow collapses. It is in the past for burrows that already collapsed,
+	// and zero for the burrows that stand while the aging is paused
+	CollapsesAt time.Time `json:"collapsesAt"`
+	// Depth is the projected depth at the end of the forecast
+	Depth float64 `json:"depth"`
+}
+
+// ForecastPoint is the expected availability at a point in time.
+type ForecastPoint struct {
+	At           time.Time `json:"at"`
+	NumAvailable int       `json:"numAvailable"`
+	FreeSlots    int       `json:"freeSlots"`
+}
+
+// NewForecast predicts when the burrows collapse and how deep they get within the horizon.
+// The availability is sampled every `step`, starting with `now`. One minute in the life of
+// a burrow lasts one tact of the speed, and none while it is paused.
+func NewForecast(burrows []Burrow, now time.Time, speed Speed, horizon, step time.Duration) (Forecast, error) {
+	tact := speed.Tact
+	if tact <= 0 || horizon <= 0 || step <= 0 {
+		return Forecast{}, errors.New("tact, horizon and step of a forecast should be positive")
+	}
+	if horizon/step >= maxForecastPoints {
+		return Forecast{}, errors.New("too many points in the forecast, use a longer step")
+	}
+
+	f := Forecast{
+		From:    now,
+		To:      now.Add(horizon),
+		Paused:  speed.Paused,
+		Burrows: make([]BurrowForecast, len(burrows)),
+	}
+
+	minsInHorizon := int(horizon / tact)
+	if speed.Paused {
+		minsInHorizon = 0
+	}
+	for i, b := range burrows {
+		lifetime := time.Duration(maxAgeInMin-b.AgeInMin) * tact
+		projected := b
+		projected.AgeBy(minsInHorizon)
+
+		f.Burrows[i] = BurrowForecast{
+			Name:        b.Name,
+			CollapsesAt: now.Add(lifetime),
+			Depth:       projected.Depth,
+		}
+		if speed.Paused && !b.IsCollapsed() {
+			f.Burrows[i].CollapsesAt = time.Time{}
+		}
+	}
+
+	// burrows with free slots stay available until they collapse.
+	// sorting them by collapse time allows sampling the availability in a single sweep
+	type expiry struct {
+		at    time.Time
+		slots int
+	}
+	var open []expiry
+	for i, b := range burrows {
+		if b.FreeSlots() > 0 {
+			at := f.Burrows[i].CollapsesAt
+			if at.IsZero() {
+				// never within the horizon
+				at = f.To.Add(step)
+			}
+			open = append(open, expiry{at: at, slots: b.FreeSlots()})
+		}
+	}
+	slices.SortFunc(open, func(a, b expiry) int { return a.at.Compare(b.at) })
+
+	p := ForecastPoint{NumAvailable: len(open)}
+	for _, e := range open {
+		p.FreeSlots += e.slots
+	}
+	collapsed := 0
+	for at := now; !at.After(f.To); at = at.Add(step) {
+		for ; collapsed < len(open) && !at.Before(open[collapsed].at); collapsed++ {
+			p.NumAvailable--
+			p.FreeSlots -= open[collapsed].slots
+		}
+		p.At = at
+		f.Availability = append(f.Availability, p)
+	}
+
+	return f, nil
+}
@@ -0,0 +1,86 @@
+package burrows
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewForecast(t *testing.T) {
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	burrows := []Burrow{
+		{Name: "collapses in 2 days", Capacity: 2, Depth: 1, AgeInMin: maxAgeInMin - 2*24*60},
+		{Name: "occupied", Capacity: 3, Depth: 1, Occupants: []string{"gopher"}, AgeInMin: 0},
+		{Name: "full", Depth: 1, Occupants: []string{"gopher"}, AgeInMin: maxAgeInMin - 24*60},
+		{Name: "collapsed", Depth: 1, AgeInMin: maxAgeInMin},
+	}
+
+	f, err := NewForecast(burrows, now, Speed{Tact: time.Minute}, 7*day, day)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedCollapse := []time.Time{now.Add(2 * day), now.Add(time.Duration(maxAgeInMin) * time.Minute), now.Add(day), now}
+	for i, b := range f.Burrows {
+		if !b.CollapsesAt.Equal(expectedCollapse[i]) {
+			t.Errorf("wrong collapse time for %s. expected: %v, got: %v", b.Name, expectedCollapse[i], b.CollapsesAt)
+		}
+	}
+
+	if f.Burrows[0].Depth != 1 || f.Burrows[1].Depth <= 1 {
+		t.Errorf("only occupied burrows should grow deeper. got: %+v", f.Burrows)
+	}
+
+	if len(f.Availability) != 8 {
+		t.Fatalf("wrong number of points. expected: 8, got: %d", len(f.Availability))
+	}
+
+	expected := []struct{ available, slots int }{{2, 4}, {2, 4}, {1, 2}, {1, 2}}
+	for i, e := range expected {
+		p := f.Availability[i]
+		if p.NumAvailable != e.available || p.FreeSlots != e.slots {
+			t.Errorf("wrong availability on day %d. expected: %v, got: %+v", i, e, p)
+		}
+	}
+}
+
+func TestNewForecastInvalid(t *testing.T) {
+
+	if _, err := NewForecast(nil, time.Now(), Speed{Tact: time.Minute}, 0, time.Hour); err == nil {
+		t.Error("expected an error for an empty horizon")
+	}
+
+	if _, err := NewForecast(nil, time.Now(), Speed{Tact: time.Minute}, 365*24*time.Hour, time.Minute); err == nil {
+		t.Error("expected an error for too many points")
+	}
+}
+
+func TestNewForecastPaused(t *testing.T) {
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	burrows := []Burrow{
+		{Name: "collapses in 2 days", Capacity: 2, Depth: 1, AgeInMin: maxAgeInMin - 2*24*60},
+		{Name: "occupied", Capacity: 3, Depth: 1, Occupants: []string{"gopher"}},
+		{Name: "collapsed", Depth: 1, AgeInMin: maxAgeInMin},
+	}
+
+	f, err := NewForecast(burrows, now, Speed{Tact: time.Minute, Paused: true}, 7*day, day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.Paused {
+		t.Error("the forecast should tell that the aging is paused")
+	}
+	if !f.Burrows[0].CollapsesAt.IsZero() || f.Burrows[1].Depth != 1 || !f.Burrows[2].CollapsesAt.Equal(now) {
+		t.Errorf("paused burrows should neither collapse nor grow deeper. got: %+v", f.Burrows)
+	}
+	for _, p := range f.Availability {
+		if p.NumAvailable != 2 || p.FreeSlots != 4 {
+			t.Errorf("the availability should stay the same while the aging is paused. got: %+v", p)
+		}
+	}
+}
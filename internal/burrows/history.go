@@ -0,0 +1,180 @@
+package burrows
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ReportHistory keeps the periodic reports as a time series.
+type ReportHistory interface {
+	// Append adds a report. Reports are usually appended in the order in which they were taken,
+	// but not after the clock was set back or the burrows were loaded from an older dump.
+	Append(r Report) error
+	// Range returns the reports taken from `from` until before `to`, oldest first, whatever the order they were appended in.
+	Range(from, to time.Time) ([]Report, error)
+	Close() error
+}
+
+// FileReportHistory appends the reports to a file, one JSON object per line.
+// The file is only read on queries, the reports of a long history never have to fit into memory at once.
+type FileReportHistory struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// NewFileReportHistory opens the history for appending, creating the file if needed.
+func NewFileReportHistory(path string) (*FileReportHistory, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o664)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := io.ReadAll(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	// drop the half written report of a crash, new reports must start on a new line
+	if end := bytes.LastIndexByte(b, '\n') + 1; end < len(b) {
+		if err := f.Truncate(int64(end)); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+
+	return &FileReportHistory{f: f}, nil
+}
+
+func (h *FileReportHistory) Append(r Report) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, err := h.f.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	return h.f.Sync()
+}
+
+func (h *FileReportHistory) Range(from, to time.Time) ([]Report, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var reports []Report
+	sc := bufio.NewScanner(io.NewSectionReader(h.f, 0, 1<<62))
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var r Report
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			return nil, errors.Join(errors.New("corrupt report history"), err)
+		}
+		if r.Taken.Before(from) || !r.Taken.Before(to) {
+			continue
+		}
+		reports = append(reports, r)
+	}
+	slices.SortStableFunc(reports, func(a, b Report) int { return a.Taken.Compare(b.Taken) })
+
+	return reports, sc.Err()
+}
+
+func (h *FileReportHistory) Close() error { return h.f.Close() }
+
+// ReportPoint is a point of the report time series, with the values that are charted over time.
+// A downsampled point averages the reports taken during its step.
+type ReportPoint struct {
+	// Taken is the start of the step, or when the report was taken if the series is not downsampled
+	Taken time.Time `json:"taken"`
+	// Reports is the number of reports that were averaged
+	Reports        int     `json:"reports"`
+	Count          float64 `json:"count"`
+	Slots          float64 `json:"slots"`
+	Occupants      float64 `json:"occupants"`
+	OccupancyRate  float64 `json:"occupancyRate"`
+	NumAvailable   float64 `json:"numAvailable"`
+	FreeSlots      float64 `json:"freeSlots"`
+	Collapsed      float64 `json:"collapsed"`
+	CollapsingSoon float64 `json:"collapsingWithin24h"`
+	TotalDepth     float64 `json:"totalDepth"`
+	TotalVolume    float64 `json:"totalVolume"`
+	AgeAvg         float64 `json:"ageAvg"`
+	DepthAvg       float64 `json:"depthAvg"`
+}
+
+// Downsample turns the reports into a time series with one point per step. Steps are aligned to multiples
+// of the step since the zero time, so that daily points start at midnight UTC.
+// A step of 0 keeps one point per report.
+func Downsample(reports []Report, step time.Duration) []ReportPoint {
+	var points []ReportPoint
+
+	for _, r := range reports {
+		start := r.Taken
+		if step > 0 {
+			start = r.Taken.Truncate(step)
+		}
+		if len(points) == 0 || step <= 0 || !points[len(points)-1].Taken.Equal(start) {
+			points = append(points, ReportPoint{Taken: start})
+		}
+
+		p := &points[len(points)-1]
+		p.Reports++
+		p.Count += float64(r.Count)
+		p.Slots += float64(r.Slots)
+		p.Occupants += float64(r.Occupants)
+		p.OccupancyRate += r.OccupancyRate
+		p.NumAvailable += float64(r.NumAvailable)
+		p.FreeSlots += float64(r.FreeSlots)
+		p.Collapsed += float64(r.Collapsed)
+		p.CollapsingSoon += float64(r.CollapsingSoon)
+		p.TotalDepth += r.TotalDepth
+		p.TotalVolume += r.TotalVolume
+		p.AgeAvg += r.Age.Avg
+		p.DepthAvg += r.Depth.Avg
+	}
+
+	for i := range points {
+		p := &points[i]
+		n := float64(p.Reports)
+		p.Count /= n
+		p.Slots /= n
+		p.Occupants /= n
+		p.OccupancyRate /= n
+		p.NumAvailable /= n
+		p.FreeSlots /= n
+		p.Collapsed /= n
+		p.CollapsingSoon /= n
+		p.TotalDepth /= n
+		p.TotalVolume /= n
+		p.AgeAvg /= n
+		p.DepthAvg /= n
+	}
+
+	return points
+}
+
+// SeriesStep is the smallest step, in whole minutes, that fits the range into at most `maxPoints` points.
+// It is 0 if the reports fit without downsampling.
+func SeriesStep(from, to time.Time, reports, maxPoints int) time.Duration {
+	if reports <= maxPoints || maxPoints <= 0 {
+		return 0
+	}
+	step := to.Sub(from) / time.Duration(maxPoints)
+	if rounded := step.Truncate(time.Minute); rounded < step {
+		step = rounded + time.Minute
+	}
+	return max(step, time.Minute)
+}
@@ -0,0 +1,114 @@
+package burrows
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestFileReportHistory(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "reports.ndjson")
+	h, err := NewFileReportHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	for i := 0; i < 6; i++ {
+		if err := h.Append(Report{Taken: start.Add(time.Duration(i) * 10 * time.Minute), FreeSlots: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reports, err := h.Range(start.Add(10*time.Minute), start.Add(40*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 3 || reports[0].FreeSlots != 1 || reports[2].FreeSlots != 3 {
+		t.Errorf("wrong reports in the range. got: %+v", reports)
+	}
+	h.Close()
+
+	// a crash while appending leaves a half written report behind
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	f.WriteString(`{"taken":"2024-03-01T11:`)
+	f.Close()
+
+	h, err = NewFileReportHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	if err := h.Append(Report{Taken: start.Add(time.Hour), FreeSlots: 6}); err != nil {
+		t.Fatal(err)
+	}
+	reports, err = h.Range(start, start.Add(2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 7 || reports[6].FreeSlots != 6 {
+		t.Errorf("the history should continue after the last complete report. got: %+v", reports)
+	}
+
+	// the clock was set back: the next reports are older than the last one, the ones in the range still count
+	for i, minutes := range []int{15, 70} {
+		if err := h.Append(Report{Taken: start.Add(time.Duration(minutes) * time.Minute), FreeSlots: 7 + i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	reports, err = h.Range(start.Add(10*time.Minute), start.Add(80*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []int
+	for _, r := range reports {
+		got = append(got, r.FreeSlots)
+	}
+	if expected := []int{1, 7, 2, 3, 4, 5, 6, 8}; !slices.Equal(expected, got) {
+		t.Errorf("the reports appended out of order should be in the range, oldest first. expected: %v, got: %v", expected, got)
+	}
+}
+
+func TestDownsample(t *testing.T) {
+
+	start := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	var reports []Report
+	for i := 0; i < 6; i++ {
+		reports = append(reports, Report{
+			Taken:         start.Add(time.Duration(i) * 20 * time.Minute),
+			TotalDepth:    float64(i),
+			NumAvailable:  10 - i,
+			OccupancyRate: float64(i) / 10,
+		})
+	}
+
+	if points := Downsample(reports, 0); len(points) != 6 || points[5].TotalDepth != 5 || points[5].Reports != 1 {
+		t.Errorf("without a step every report should be a point. got: %+v", points)
+	}
+
+	points := Downsample(reports, time.Hour)
+	if len(points) != 2 {
+		t.Fatalf("expected a point per hour, got: %+v", points)
+	}
+	expected := ReportPoint{Taken: start.Add(time.Hour), Reports: 3, TotalDepth: 4, NumAvailable: 6, OccupancyRate: 0.4}
+	if p := points[1]; !p.Taken.Equal(expected.Taken) || p.Reports != 3 || p.TotalDepth != expected.TotalDepth ||
+		p.NumAvailable != expected.NumAvailable || p.OccupancyRate < 0.399 || p.OccupancyRate > 0.401 {
+		t.Errorf("wrong downsampled point. expected: %+v, got: %+v", expected, p)
+	}
+}
+
+func TestSeriesStep(t *testing.T) {
+
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(30 * 24 * time.Hour)
+
+	if step := SeriesStep(from, to, 500, 1000); step != 0 {
+		t.Errorf("reports that fit should not be downsampled. got: %s", step)
+	}
+	if step := SeriesStep(from, to, 43200, 1000); step != 44*time.Minute {
+		t.Errorf("wrong step. expected: 44m, got: %s", step)
+	}
+}
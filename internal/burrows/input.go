@@ -0,0 +1,285 @@
+package burrows
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Format is the encoding of a data file.
+type Format string
+
+const (
+	// FormatJSON is a snapshot of any schema version, see `ReadSnapshot`
+	FormatJSON Format = "json"
+	// FormatYAML has the same structure as FormatJSON
+	FormatYAML Format = "yaml"
+	// FormatNDJSON has one burrow per line
+	FormatNDJSON Format = "ndjson"
+	// FormatCSV has one burrow per row, see `csvColumns`
+	FormatCSV Format = "csv"
+)
+
+// ParseFormat returns the format of data files with the given name. See `ParseExportFormat` for the exports.
+func ParseFormat(name string) (Format, error) {
+	switch f := Format(strings.ToLower(name)); f {
+	case FormatJSON, FormatYAML, FormatNDJSON, FormatCSV:
+		return f, nil
+	case "yml":
+		return FormatYAML, nil
+	case "jsonl":
+		return FormatNDJSON, nil
+	default:
+		return "", fmt.Errorf("unknown format: %s", name)
+	}
+}
+
+// FormatOf detects the format of a data file from its extension. It defaults to JSON.
+func FormatOf(path string) Format {
+	f, err := ParseFormat(strings.TrimPrefix(filepath.Ext(path), "."))
+	if err != nil {
+		return FormatJSON
+	}
+	return f
+}
+
+// Decoder reads the burrows of a data file one by one.
+type Decoder interface {
+	// Next returns the next burrow or io.EOF once all the burrows were read.
+	Next() (Burrow, error)
+	// Taken is when the data was written, zero if unknown.
+	Taken() time.Time
+}
+
+// NewDecoder reads burrows in the given format.
+// NDJSON and CSV are decoded as a stream, so the data file does not need to fit into memory.
+// JSON and YAML are read as a whole, because they are migrated and verified first.
+func NewDecoder(r io.Reader, format Format) (Decoder, error) {
+	switch format {
+	case FormatJSON:
+		s, err := ReadSnapshot(r)
+		if err != nil {
+			return nil, err
+		}
+		return NewSnapshotDecoder(s), nil
+	case FormatYAML:
+		return newYAMLDecoder(r)
+	case FormatNDJSON:
+		return &ndjsonDecoder{dec: json.NewDecoder(r)}, nil
+	case FormatCSV:
+		return newCSVDecoder(r)
+	default:
+		return nil, fmt.Errorf("burrows can not be read from %s", format)
+	}
+}
+
+// NewSnapshotDecoder returns the burrows of a snapshot that was already read.
+func NewSnapshotDecoder(s Snapshot) Decoder {
+	return &snapshotDecoder{s: s}
+}
+
+type snapshotDecoder struct {
+	s    Snapshot
+	next int
+}
+
+func (d *snapshotDecoder) Next() (Burrow, error) {
+	if d.next >= len(d.s.Burrows) {
+		return Burrow{}, io.EOF
+	}
+	d.next++
+	b := d.s.Burrows[d.next-1]
+	if err := validate(b); err != nil {
+		return Burrow{}, fmt.Errorf("burrow %d: %w", d.next, err)
+	}
+	return b, nil
+}
+
+func (d *snapshotDecoder) Taken() time.Time { return d.s.Taken }
+
+// newYAMLDecoder converts the YAML document to JSON, so that it is migrated and verified like a JSON snapshot
+func newYAMLDecoder(r io.Reader) (Decoder, error) {
+	var doc any
+	if err := yaml.NewDecoder(r).Decode(&doc); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if doc == nil {
+		doc = []any{}
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	s, err := ReadSnapshot(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	return NewSnapshotDecoder(s), nil
+}
+
+type ndjsonDecoder struct {
+	dec  *json.Decoder
+	read int
+}
+
+func (d *ndjsonDecoder) Next() (Burrow, error) {
+	var b Burrow
+	if err := d.dec.Decode(&b); err != nil {
+		if errors.Is(err, io.EOF) {
+			return Burrow{}, io.EOF
+		}
+		return Burrow{}, fmt.Errorf("burrow %d: %w", d.read+1, err)
+	}
+	d.read++
+	if err := validate(b); err != nil {
+		return Burrow{}, fmt.Errorf("burrow %d: %w", d.read, err)
+	}
+	return b, nil
+}
+
+func (d *ndjsonDecoder) Taken() time.Time { return time.Time{} }
+
+// csvColumns are the columns of a CSV data file. Only the name is required, the header row decides the order.
+// Occupants are separated by `;` and labels are written as `key=value;key=value`.
+// The occupants can also be in a `tenant` column, like in an export. Other columns are ignored.
+// A `removed` column set to true decommissions the burrow, see `Manager.Reconcile`.
+var csvColumns = []string{"name", "capacity", "occupants", "depth", "width", "age", "labels", "removed"}
+
+type csvDecoder struct {
+	r *csv.Reader
+	// columns maps the known columns to their position
+	columns map[string]int
+}
+
+func newCSVDecoder(r io.Reader) (Decoder, error) {
+	d := &csvDecoder{r: csv.NewReader(r), columns: make(map[string]int)}
+	d.r.ReuseRecord = true
+	d.r.FieldsPerRecord = -1
+
+	header, err := d.r.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("no header row")
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i, h := range header {
+		d.columns[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	if _, ok := d.columns["name"]; !ok {
+		return nil, errors.New("no name column")
+	}
+
+	return d, nil
+}
+
+func (d *csvDecoder) Next() (Burrow, error) {
+	record, err := d.r.Read()
+	if errors.Is(err, io.EOF) {
+		return Burrow{}, io.EOF
+	}
+	if err != nil {
+		return Burrow{}, err
+	}
+
+	line, _ := d.r.FieldPos(0)
+	b, err := d.parse(record)
+	if err == nil {
+		err = validate(b)
+	}
+	if err != nil {
+		return Burrow{}, fmt.Errorf("line %d: %w", line, err)
+	}
+	return b, nil
+}
+
+func (d *csvDecoder) parse(record []string) (Burrow, error) {
+	field := func(column string) string {
+		i, ok := d.columns[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	b := Burrow{Name: field("name")}
+
+	var err error
+	if v := field("capacity"); v != "" {
+		if b.Capacity, err = strconv.Atoi(v); err != nil {
+			return b, fmt.Errorf("invalid capacity: %w", err)
+		}
+	}
+	occupants := field("occupants")
+	if occupants == "" {
+		occupants = field("tenant")
+	}
+	if occupants != "" {
+		for _, o := range strings.Split(occupants, ";") {
+			if o = strings.TrimSpace(o); o != "" {
+				b.Occupants = append(b.Occupants, o)
+			}
+		}
+	}
+	if v := field("depth"); v != "" {
+		if b.Depth, err = strconv.ParseFloat(v, 64); err != nil {
+			return b, fmt.Errorf("invalid depth: %w", err)
+		}
+	}
+	if v := field("width"); v != "" {
+		if b.Width, err = strconv.ParseFloat(v, 64); err != nil {
+			return b, fmt.Errorf("invalid width: %w", err)
+		}
+	}
+	if v := field("age"); v != "" {
+		if b.AgeInMin, err = strconv.Atoi(v); err != nil {
+			return b, fmt.Errorf("invalid age: %w", err)
+		}
+	}
+	if v := field("labels"); v != "" {
+		b.Labels = make(map[string]string)
+		for _, pair := range strings.Split(v, ";") {
+			key, value, ok := strings.Cut(pair, "=")
+			if !ok {
+				return b, fmt.Errorf("invalid label: %s", pair)
+			}
+			b.Labels[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	if v := field("removed"); v != "" {
+		if b.Removed, err = strconv.ParseBool(v); err != nil {
+			return b, fmt.Errorf("invalid removed: %w", err)
+		}
+	}
+
+	return b, nil
+}
+
+func (d *csvDecoder) Taken() time.Time { return time.Time{} }
+
+// validate checks a burrow of a data file, whatever the format: it needs a name and valid labels,
+// and its occupants have to fit into it like after `MoveIn`
+func validate(b Burrow) error {
+	if b.Name == "" {
+		return errors.New("no name")
+	}
+	if len(b.Occupants) > b.Slots() {
+		return fmt.Errorf("%d occupants, more than the capacity of %d", len(b.Occupants), b.Slots())
+	}
+	for i, g := range b.Occupants {
+		if slices.Contains(b.Occupants[:i], g) {
+			return fmt.Errorf("gopher %s is an occupant twice", g)
+		}
+	}
+	return ValidateLabels(b.Labels)
+}
@@ -0,0 +1,151 @@
+package burrows
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFormatOf(t *testing.T) {
+
+	scenarios := map[string]Format{
+		"data/initial.json": FormatJSON,
+		"inventory.CSV":     FormatCSV,
+		"burrows.yml":       FormatYAML,
+		"burrows.yaml":      FormatYAML,
+		"export.ndjson":     FormatNDJSON,
+		"export.jsonl":      FormatNDJSON,
+		"dump":              FormatJSON,
+	}
+
+	for path, expected := range scenarios {
+		if got := FormatOf(path); got != expected {
+			t.Errorf("wrong format of %s. expected: %s, got: %s", path, expected, got)
+		}
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+
+	if f, err := ParseFormat("YAML"); err != nil || f != FormatYAML {
+		t.Errorf("yaml should be a format of data files. got: %q, %v", f, err)
+	}
+	if _, err := ParseFormat("text"); err == nil {
+		t.Error("text is not a format of data files")
+	}
+	if f, err := ParseExportFormat("text"); err != nil || f != FormatText {
+		t.Errorf("text should be a format of exports. got: %q, %v", f, err)
+	}
+}
+
+func TestDecoder(t *testing.T) {
+
+	expected := []Burrow{
+		{Name: "The Molehole", Capacity: 4, Occupants: []string{"Gus", "Goldie"}, Depth: 3, Width: 1.3, AgeInMin: 50, Labels: map[string]string{"site": "south", "tier": "premium"}},
+		{Name: "Tunnel of Mystery", Depth: 1.8, Width: 1.1, AgeInMin: 30},
+	}
+
+	scenarios := []struct {
+		format Format
+		data   string
+	}{
+		{format: FormatJSON, data: `{"version": 3, "burrows": [
+			{"name": "The Molehole", "capacity": 4, "occupants": ["Gus", "Goldie"], "depth": 3, "width": 1.3, "age": 50, "labels": {"site": "south", "tier": "premium"}},
+			{"name": "Tunnel of Mystery", "depth": 1.8, "width": 1.1, "age": 30}]}`},
+		{format: FormatNDJSON, data: `{"name": "The Molehole", "capacity": 4, "occupants": ["Gus", "Goldie"], "depth": 3, "width": 1.3, "age": 50, "labels": {"site": "south", "tier": "premium"}}
+
+{"name": "Tunnel of Mystery", "depth": 1.8, "width": 1.1, "age": 30}
+`},
+		{format: FormatCSV, data: `name,depth,width,age,capacity,occupants,labels,owner
+The Molehole,3,1.3,50,4,Gus;Goldie,site=south;tier=premium,ops
+"Tunnel of Mystery",1.8,1.1,30,,,,
+`},
+		{format: FormatYAML, data: `
+version: 3
+burrows:
+  - name: The Molehole
+    capacity: 4
+    occupants: [Gus, Goldie]
+    depth: 3
+    width: 1.3
+    age: 50
+    labels:
+      site: south
+      tier: premium
+  - name: Tunnel of Mystery
+    depth: 1.8
+    width: 1.1
+    age: 30
+`},
+	}
+
+	for _, s := range scenarios {
+		t.Run(string(s.format), func(t *testing.T) {
+			t.Parallel()
+
+			dec, err := NewDecoder(strings.NewReader(s.data), s.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []Burrow
+			for {
+				b, err := dec.Next()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, b)
+			}
+
+			if !reflect.DeepEqual(expected, got) {
+				t.Errorf("wrong burrows.\nexpected: %+v\ngot:      %+v", expected, got)
+			}
+		})
+	}
+}
+
+func TestDecoderInvalid(t *testing.T) {
+
+	scenarios := []struct {
+		name   string
+		format Format
+		data   string
+	}{
+		{name: "csv without name column", format: FormatCSV, data: "depth,width\n1,2\n"},
+		{name: "csv without name", format: FormatCSV, data: "name,depth\n,2\n"},
+		{name: "csv invalid number", format: FormatCSV, data: "name,depth\none,deep\n"},
+		{name: "csv invalid label", format: FormatCSV, data: "name,labels\none,site\n"},
+		{name: "ndjson broken line", format: FormatNDJSON, data: "{\"name\": \"one\"}\n{\"name\": \n"},
+		{name: "ndjson without name", format: FormatNDJSON, data: "{\"depth\": 1}\n"},
+		{name: "ndjson invalid label", format: FormatNDJSON, data: "{\"name\": \"one\", \"labels\": {\"site\": \"north side\"}}\n"},
+		{name: "json without name", format: FormatJSON, data: "[{\"depth\": 1}]"},
+		{name: "json invalid label", format: FormatJSON, data: "[{\"name\": \"one\", \"labels\": {\"north side\": \"yes\"}}]"},
+		{name: "csv more occupants than capacity", format: FormatCSV, data: "name,capacity,occupants\none,1,a;b\n"},
+		{name: "csv same occupant twice", format: FormatCSV, data: "name,capacity,occupants\none,3,a;b;a\n"},
+		{name: "ndjson more occupants than capacity", format: FormatNDJSON, data: "{\"name\": \"one\", \"capacity\": 2, \"occupants\": [\"a\", \"b\", \"c\"]}\n"},
+		{name: "json same occupant twice", format: FormatJSON, data: "[{\"name\": \"one\", \"capacity\": 2, \"occupants\": [\"a\", \"a\"]}]"},
+		{name: "yaml more occupants than capacity", format: FormatYAML, data: "burrows:\n  - name: one\n    occupants: [a, b]\n"},
+		{name: "yaml newer version", format: FormatYAML, data: "version: 99\nburrows: []\n"},
+		{name: "yaml without name", format: FormatYAML, data: "burrows:\n  - depth: 1\n"},
+		{name: "yaml invalid label", format: FormatYAML, data: "burrows:\n  - name: one\n    labels:\n      site: north side\n"},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			t.Parallel()
+
+			dec, err := NewDecoder(strings.NewReader(s.data), s.format)
+			for err == nil {
+				_, err = dec.Next()
+			}
+			if errors.Is(err, io.EOF) {
+				t.Error("invalid data should fail")
+			}
+		})
+	}
+}
@@ -1,100 +1,302 @@
 package burrows
 
 import (
+	"cmp"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"log/slog"
-	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// tact is used for testing in order to make the time go faster. Normally it should be set to 1 minute.
-var Tact = time.Minute
+// ErrNoBurrowAvailable is returned when no burrow can host the gophers of a rental.
+var ErrNoBurrowAvailable = errors.New("no burrow available")
 
-type Report struct {
-	TotalDepth    float64
-	NumAvailable  int
-	VolumeMin     float64
-	VolumeMinName string
-	VolumeMax     float64
-	VolumeMaxName string
-}
+// ErrNoRoom is returned by a burrow that does not have enough free capacity for all the gophers.
+var ErrNoRoom = errors.New("not enough room in the burrow")
+
+// ErrUnknownBurrow is returned when no burrow has the requested name.
+var ErrUnknownBurrow = errors.New("unknown burrow")
 
-func (r Report) Write(w io.Writer) error {
+// ErrNotOccupant is returned when a gopher should move out of a burrow it does not live in.
+var ErrNotOccupant = errors.New("gopher does not live in the burrow")
 
-	txt := `TotalDepth	%.3f	
-NumAvailable	%d	
-VolumeMinName	%s	
-VolumeMaxName	%s	
-`
+// ErrAlreadyHoused is returned when a gopher of a rental already lives in a burrow or is being placed by another rental.
+var ErrAlreadyHoused = errors.New("gopher already lives in a burrow")
 
-	_, err := fmt.Fprintf(w, txt, r.TotalDepth, r.NumAvailable, r.VolumeMinName, r.VolumeMaxName)
+// gopherSeq and startedAt name the gophers of anonymous rentals
+var (
+	gopherSeq atomic.Int64
+	startedAt = time.Now().Unix()
+)
 
-	return err
+// Rental describes a request to house one or more gophers (a family) in the same burrow.
+// If no gophers are named then a single anonymous gopher moves in.
+// The selector restricts the burrows that can be rented, ex: `site=north,tier=premium`
+type Rental struct {
+	Gophers  []string `json:"gophers"`
+	Selector Selector `json:"selector"`
 }
 
 type Manager interface {
 	Load(<-chan Burrow)
 	CurrentStatus() []Burrow
-	Rentout(ctx context.Context) (Burrow, error)
-	Report() Report
+	Rentout(ctx context.Context, rental Rental) (Burrow, error)
+	SetLabels(name string, labels map[string]string) (Burrow, error)
+	Vacate(name string, gophers []string) (Burrow, error)
+	Reconcile(records []Burrow) Reconciliation
+	Report(sel Selector) Report
+	Forecast(horizon, step time.Duration) (Forecast, error)
+
+	Speed() Speed
+	Pause() Speed
+	Resume() Speed
+	SetTact(tact time.Duration) (Speed, error)
+	Advance(d time.Duration) (Speed, error)
+}
+
+// Reconciliation names the burrows that changed when the manager was reconciled with records of the inventory.
+type Reconciliation struct {
+	Added   []string `json:"added,omitempty"`
+	Updated []string `json:"updated,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// Merge appends the changes of another reconciliation.
+func (r *Reconciliation) Merge(other Reconciliation) {
+	r.Added = append(r.Added, other.Added...)
+	r.Updated = append(r.Updated, other.Updated...)
+	r.Removed = append(r.Removed, other.Removed...)
+}
+
+// Empty returns `true` if nothing changed.
+func (r Reconciliation) Empty() bool {
+	return len(r.Added) == 0 && len(r.Updated) == 0 && len(r.Removed) == 0
 }
 
 type manager struct {
 	lg *slog.Logger
 
+	clock Clock
+	// tact is the real duration of one minute in the life of a burrow.
+	// Normally it is 1 minute but it can be made shorter to make the time go faster.
+	// It is the initial speed, at runtime the speed is owned by the manage loop
+	tact time.Duration
+
+	// dumps is where the status of all the burrows is written out, periodically and on shutdown
+	dumps Dumps
+	// snapshotEvery is the interval of the periodic snapshots. 0 only takes a snapshot on shutdown
+	snapshotEvery time.Duration
+
+	// store persists every change of the burrows as it happens
+	store Store
+	// events records every change of the burrows as it happens
+	events EventLog
+
 	// only internal. should not be accessed directly. use the list channel
-	burrows []managedBurrow
+	shards []shard
+	// count is the number of burrows that were added to the shards.
+	// Decommissioned burrows don't free room, so only the last shard takes new burrows
+	count int
+
+	// list receives requests to expose the list of shards
+	list chan chan shard
+
+	// incoming receives batches of new burrows
+	incoming chan []Burrow
+
+	// syncs receives records of the inventory to reconcile the burrows with
+	syncs chan syncRequest
 
-	// list receives requests to expose the list of managedBurrows
-	list chan chan managedBurrow
+	// speed receives requests to change how fast the burrows age
+	speed chan speedRequest
 
-	incoming chan Burrow
+	// placing are the gophers of the rentals in progress, so that two rentals can't house the same gopher
+	placingMu sync.Mutex
+	placing   map[string]bool
 
 	// Done will be closed by the manager once all cleanup is done
 	Done chan struct{}
 }
 
+// Option changes the default configuration of a manager.
+type Option func(*manager)
+
+// WithClock replaces the wall clock that drives the aging of the burrows.
+func WithClock(c Clock) Option {
+	return func(m *manager) { m.clock = c }
+}
+
+// WithTact changes how often the burrows age by one minute.
+func WithTact(d time.Duration) Option {
+	return func(m *manager) { m.tact = d }
+}
+
+// WithDumpDir changes the directory where the dump files are written.
+// An empty directory disables the dumps.
+func WithDumpDir(dir string) Option {
+	return func(m *manager) { m.dumps.Dir = dir }
+}
+
+// WithSnapshots writes a dump file at every interval, not only on shutdown.
+func WithSnapshots(every time.Duration) Option {
+	return func(m *manager) { m.snapshotEvery = every }
+}
+
+// WithRetention removes the oldest dumps when there are more than `keep` of them
+// and the dumps older than `maxAge`. Zero values disable the limit.
+func WithRetention(keep int, maxAge time.Duration) Option {
+	return func(m *manager) {
+		m.dumps.Keep = keep
+		m.dumps.MaxAge = maxAge
+	}
+}
+
+// WithStore persists every change of the burrows in the store.
+// The manager does not load the burrows from the store, use `Load` for that.
+func WithStore(s Store) Option {
+	return func(m *manager) { m.store = s }
+}
+
+// WithEventLog appends every change of the burrows to the event log before it is acknowledged.
+// The manager does not replay the log, use `Replay` and `Load` for that.
+func WithEventLog(l EventLog) Option {
+	return func(m *manager) { m.events = l }
+}
+
 // NewManager creates a new burrows manager.
 // It starts a go routine that manages the lifecycle of the manager
-func NewManager(ctx context.Context, logger *slog.Logger) *manager {
+func NewManager(ctx context.Context, logger *slog.Logger, opts ...Option) *manager {
 	m := &manager{
 		lg:       logger,
-		list:     make(chan chan managedBurrow),
-		incoming: make(chan Burrow),
+		clock:    RealClock,
+		tact:     time.Minute,
+		dumps:    Dumps{Dir: "."},
+		store:    NopStore,
+		events:   NopEventLog,
+		list:     make(chan chan shard),
+		incoming: make(chan []Burrow),
+		syncs:    make(chan syncRequest),
+		speed:    make(chan speedRequest),
+		placing:  make(map[string]bool),
 		Done:     make(chan struct{}),
 	}
-	go m.manage(ctx)
+	for _, opt := range opts {
+		opt(m)
+	}
+	// the tickers exist before the function returns so that no tick of the clock can be missed
+	var snapshots Ticker
+	if m.snapshotEvery > 0 && m.dumps.Dir != "" {
+		snapshots = m.clock.NewTicker(m.snapshotEvery)
+	}
+	go m.manage(ctx, m.clock.NewTicker(m.tact), snapshots)
 	return m
 }
 
-func (m *manager) manage(ctx context.Context) {
+// manage owns the list of shards and is the only scheduler of the burrows.
+// Every tick of the clock ages all the burrows by one minute, one shard at a time.
+// Periodic snapshots are written in the background, the burrows keep aging meanwhile.
+func (m *manager) manage(ctx context.Context, pulse Ticker, snapshots Ticker) {
 	m.lg.Debug("start manage")
 
 	defer close(m.Done)
 
+	var snapshotTicks <-chan time.Time
+	if snapshots != nil {
+		defer snapshots.Stop()
+		snapshotTicks = snapshots.C()
+	}
+	// writing is closed when the current snapshot is written. it is nil while no snapshot is written
+	var writing chan struct{}
+
+	speed := Speed{Tact: m.tact}
+	// ticks is nil, and never delivers, while the aging is paused
+	ticks := pulse.C()
+	defer func() {
+		if pulse != nil {
+			pulse.Stop()
+		}
+	}()
+
 	for {
 		select {
 		case <-ctx.Done():
 			m.lg.Info("received closing signal", "service", "manager")
+			if writing != nil {
+				<-writing
+			}
 			// Save data if needed
 			m.closeBurrowsAndDumpStatus()
 			return
-		case b := <-m.incoming:
-			managedBurrow := NewManagedBurrow(m.lg, b)
-			m.burrows = append(m.burrows, managedBurrow)
-			m.lg.Info("managing new burrow", "name", b.Name)
+		case <-snapshotTicks:
+			if writing != nil {
+				m.lg.Warn("snapshot skipped, the previous one is still being written")
+				continue
+			}
+			taken := m.clock.Now()
+			burrows, sizes, err := m.checkpoint(ReqCheckpoint)
+			snapshot := Snapshot{Taken: taken, Burrows: burrows}
+			writing = make(chan struct{})
+			go func(done chan struct{}) {
+				defer close(done)
+				m.dump(snapshot, sizes, err)
+			}(writing)
+		case <-writing:
+			writing = nil
+		case <-ticks:
+			tick := NewTickRequest(1)
+			for _, sh := range m.shards {
+				sh.requests <- tick
+			}
+		case req := <-m.speed:
+			if req.advance > 0 {
+				tick := NewTickRequest(req.advance)
+				for _, sh := range m.shards {
+					sh.requests <- tick
+				}
+				m.lg.Info("burrows advanced", "minutes", req.advance)
+			}
+			if req.change == nil {
+				req.response <- speedResponse{speed: speed}
+				continue
+			}
+
+			changed := speed
+			if err := req.change(&changed); err != nil {
+				req.response <- speedResponse{speed: speed, err: err}
+				continue
+			}
+			if changed != speed {
+				if pulse != nil {
+					pulse.Stop()
+					pulse, ticks = nil, nil
+				}
+				if !changed.Paused {
+					pulse = m.clock.NewTicker(changed.Tact)
+					ticks = pulse.C()
+				}
+				speed = changed
+				m.lg.Info("speed changed", "tact", speed.Tact, "paused", speed.Paused)
+			}
+			req.response <- speedResponse{speed: speed}
+		case batch := <-m.incoming:
+			m.lg.Info("managing new burrows", "count", len(batch))
+			m.add(batch)
+		case req := <-m.syncs:
+			req.response <- m.sync(req.records)
 		case lst := <-m.list:
+			shards := m.shards
 			go func() {
 				defer close(lst)
-				for _, b := range m.burrows {
+				for _, sh := range shards {
 					select {
 					case <-ctx.Done():
 						return
-					case lst <- b:
+					case lst <- sh:
 					}
 				}
 			}()
@@ -102,133 +304,395 @@ func (m *manager) manage(ctx context.Context) {
 	}
 }
 
-func (m *manager) closeBurrowsAndDumpStatus() {
-	resp := make(chan Response, 2)
-	for _, b := range m.burrows {
-		// send me your current status and close
-		b.requests <- Request{name: ReqClose, response: resp}
+// add hands the new burrows over to the shards. Only the manage loop calls it
+func (m *manager) add(batch []Burrow) {
+	// fill up the last shard before starting a new one
+	for len(batch) > 0 {
+		room := shardSize - m.count%shardSize
+		part := batch[:min(room, len(batch))]
+		batch = batch[len(part):]
+
+		if room == shardSize {
+			m.shards = append(m.shards, newShard(m, len(m.shards), part))
+		} else {
+			m.shards[len(m.shards)-1].requests <- NewAddRequest(part)
+		}
+		m.count += len(part)
+	}
+}
+
+type syncRequest struct {
+	records  []Burrow
+	response chan Reconciliation
+}
+
+// sync reconciles the shards with the records and adds the records that no shard owns.
+// Only the manage loop calls it, so no other change of the list of burrows happens meanwhile.
+func (m *manager) sync(records []Burrow) Reconciliation {
+	var rec Reconciliation
+	matched := make(map[string]bool)
+	for _, sh := range m.shards {
+		resp := make(chan Response, 1)
+		sh.requests <- NewSyncRequest(records, resp)
+		r := <-resp
+		for _, name := range r.matched {
+			matched[name] = true
+		}
+		for _, b := range r.burrows {
+			rec.Updated = append(rec.Updated, b.Name)
+		}
+		for _, b := range r.removed {
+			rec.Removed = append(rec.Removed, b.Name)
+		}
+	}
+
+	// the last record of a burrow wins
+	var added []Burrow
+	position := make(map[string]int)
+	for _, r := range records {
+		if matched[r.Name] || r.Removed {
+			continue
+		}
+		if i, ok := position[r.Name]; ok {
+			added[i] = r
+			continue
+		}
+		position[r.Name] = len(added)
+		added = append(added, r)
+		rec.Added = append(rec.Added, r.Name)
+	}
+	if len(added) > 0 {
+		m.lg.Info("managing new burrows", "count", len(added))
+		m.add(added)
 	}
+
+	return rec
+}
+
+func (m *manager) closeBurrowsAndDumpStatus() {
+	taken := m.clock.Now()
+	// send me your current status and close
+	all, sizes, err := m.checkpoint(ReqClose)
+	m.dump(Snapshot{Taken: taken, Burrows: all}, sizes, err)
+}
+
+// checkpoint collects the burrows of all the shards for a snapshot, `ReqClose` also closes the shards.
+// Every shard marks the end of its events that are part of the snapshot in the event log.
+// It returns the number of burrows of every shard and an error if a checkpoint is missing. Only the manage loop calls it
+func (m *manager) checkpoint(name requestType) ([]Burrow, []int, error) {
 	var all []Burrow
-	for range len(m.burrows) {
+	var sizes []int
+	var errs []error
+	for _, sh := range m.shards {
+		resp := make(chan Response, 1)
+		sh.requests <- Request{name: name, response: resp}
 		r := <-resp
-		all = append(all, r.burrow)
+		all = append(all, r.burrows...)
+		sizes = append(sizes, len(r.burrows))
+		errs = append(errs, r.err)
+	}
+	return all, sizes, errors.Join(errs...)
+}
+
+// dump writes the snapshot to the dump directory and drops the events that are part of it from the event log.
+// Then it removes the dumps that are not retained anymore. `logged` is the error of the checkpoints of the snapshot
+func (m *manager) dump(snapshot Snapshot, sizes []int, logged error) {
+	if m.dumps.Dir == "" {
+		return
 	}
-	fpath, err := os.CreateTemp(".", "dump_*.json")
+
+	path, err := m.dumps.Write(snapshot)
 	if err != nil {
-		m.lg.Error("dump file not created", "error", err.Error())
-	} else {
-		if err = json.NewEncoder(fpath).Encode(all); err == nil {
-			m.lg.Info("generated dump file", "path", fpath.Name())
-		}
+		m.lg.Error("dump file not written", "dir", m.dumps.Dir, "error", err.Error())
+		return
+	}
+	m.lg.Info("generated dump file", "path", path, "burrows", len(snapshot.Burrows))
+
+	// the event log still starts from an older dump, which has to be kept
+	if logged == nil {
+		logged = m.events.Truncate(Dump{Path: path, Taken: snapshot.Taken}, sizes)
+	}
+	if logged != nil {
+		m.lg.Error("event log not truncated, old dump files are kept", "path", path, "error", logged.Error())
+		return
+	}
+
+	removed, err := m.dumps.Prune(snapshot.Taken)
+	if err != nil {
+		m.lg.Error("old dump files not removed", "dir", m.dumps.Dir, "error", err.Error())
+	}
+	if len(removed) > 0 {
+		m.lg.Info("removed old dump files", "paths", removed)
 	}
 }
 
 // Load reads data from the incoming channel and stores it in the internal structure of the manager.
+// Burrows that are ready at the same time are handed over in batches, which keeps the number of
+// writes to the store low.
+// Records of decommissioned burrows are skipped, they only matter to the reconciliation of a running manager.
+// It returns once all the burrows are managed.
 // It is safe to call `Load` in a separate go routine
 func (m *manager) Load(in <-chan Burrow) {
 	for b := range in {
-		m.incoming <- b
+		if b.Removed {
+			m.lg.Debug("decommissioned burrow not loaded", "name", b.Name)
+			continue
+		}
+		batch := []Burrow{b}
+	collect:
+		for len(batch) < shardSize {
+			select {
+			case b, ok := <-in:
+				if !ok {
+					break collect
+				}
+				if b.Removed {
+					m.lg.Debug("decommissioned burrow not loaded", "name", b.Name)
+					continue
+				}
+				batch = append(batch, b)
+			default:
+				break collect
+			}
+		}
+		m.incoming <- batch
+	}
+	// the manager handles one message at a time, so by the time the list
+	// is streamed all the incoming burrows have been taken care of
+	for range m.stream() {
 	}
 }
 
 // CurrentStatus returns a list of all the burrows currently managed.
+// The burrows are listed in the order in which they were loaded.
 func (m *manager) CurrentStatus() []Burrow {
 
-	ch := make(chan Response)
-
-	req := NewStatusRequest(ch)
-	count := 0
-	for mb := range m.stream() {
-		count++
-		go func() { mb.requests <- req }()
+	var responses []chan Response
+	for sh := range m.stream() {
+		ch := make(chan Response, 1)
+		responses = append(responses, ch)
+		go func() { sh.requests <- NewStatusRequest(ch) }()
 	}
 
-	burrows := make([]Burrow, count)
-	for i := 0; i < count; i++ {
+	var burrows []Burrow
+	for _, ch := range responses {
 		resp := <-ch
-		burrows[i] = resp.burrow
+		burrows = append(burrows, resp.burrows...)
 	}
 
 	return burrows
 }
 
-// Rentout picks the first available burrow and assigns it to a gopher by returning it to the caller.
-// If no available burrow can be found then an error is returned.
+// Rentout finds a burrow with enough free capacity for all the gophers of the rental and moves them in.
+// The burrow is picked according to the placement rules (see `placementOrder`). If no burrow can host
+// the whole family then an error is returned, as well as if one of the gophers already lives in a burrow.
 // The passed in context can control how long the renting process can last. It returns an error if
 // the context expires before a burrow could be rented out.
-func (m *manager) Rentout(ctx context.Context) (Burrow, error) {
+func (m *manager) Rentout(ctx context.Context, rental Rental) (Burrow, error) {
 
-	m.lg.Info("start rentout request")
+	gophers := rental.Gophers
+	if len(gophers) == 0 {
+		gophers = []string{newGopherName()}
+	}
 
-	req := NewAvailableRequest()
+	m.lg.Info("start rentout request", "gophers", gophers, "selector", rental.Selector.String())
 
-	// first prepare to receive responses
-	respB, respErrs := make(chan Burrow, 1), make(chan error, 1)
-	go func() {
-		select {
-		case <-ctx.Done():
-			m.lg.Debug("context expired before burrows responded to Available request")
-			respB <- Burrow{}
-			respErrs <- errors.New("no burrow available")
-		case resp := <-req.response:
-			m.lg.Debug("available burrow", "name", resp.burrow.Name)
-			gReq := NewGopherRequest()
-			resp.nextRequest <- gReq
+	if err := m.startPlacing(gophers); err != nil {
+		return Burrow{}, err
+	}
+	defer m.donePlacing(gophers)
 
+	// ask every shard for its status, keeping track of who owns which burrow
+	type candidate struct {
+		sh     shard
+		burrow Burrow
+	}
+	answers := make(chan []candidate)
+	count := 0
+	for sh := range m.stream() {
+		count++
+		go func() {
+			resp := make(chan Response, 1)
 			select {
 			case <-ctx.Done():
-				m.lg.Debug("available burrow did not respond in time")
-				respB <- Burrow{}
-				respErrs <- errors.New("available burrow did not respond in time")
-			case resp := <-gReq.response:
-				respB <- resp.burrow
-				respErrs <- nil
+				answers <- nil
+			case sh.requests <- NewStatusRequest(resp):
+				var cs []candidate
+				for _, b := range (<-resp).burrows {
+					cs = append(cs, candidate{sh: sh, burrow: b})
+				}
+				answers <- cs
+			}
+		}()
+	}
+
+	var fitting []candidate
+	var housed error
+	for range count {
+		for _, c := range <-answers {
+			for _, g := range gophers {
+				if housed == nil && slices.Contains(c.burrow.Occupants, g) {
+					housed = fmt.Errorf("%w: %s lives in %s", ErrAlreadyHoused, g, c.burrow.Name)
+				}
+			}
+			if c.burrow.FreeSlots() >= len(gophers) && rental.Selector.Matches(c.burrow.Labels) {
+				fitting = append(fitting, c)
 			}
 		}
-	}()
+	}
+	if housed != nil {
+		return Burrow{}, housed
+	}
+	slices.SortStableFunc(fitting, func(a, b candidate) int { return placementOrder(a.burrow, b.burrow) })
 
-	// ask who is available
-	m.lg.Debug("send available request to all burrows")
-	for mb := range m.stream() {
-		go func() { mb.requests <- req }()
+	// other rentals may fill the burrow in the meantime, so the burrow has the last word
+	for _, c := range fitting {
+		req := NewGopherRequest(c.burrow.Name, gophers)
+		select {
+		case <-ctx.Done():
+			m.lg.Debug("context expired before a burrow accepted the gophers")
+			return Burrow{}, ErrNoBurrowAvailable
+		case c.sh.requests <- req:
+		}
+
+		resp := <-req.response
+		if resp.err == nil {
+			m.lg.Debug("rented burrow", "name", resp.burrow.Name)
+			return resp.burrow, nil
+		}
+		if !errors.Is(resp.err, ErrNoRoom) && !errors.Is(resp.err, ErrUnknownBurrow) {
+			return Burrow{}, resp.err
+		}
+		m.lg.Debug("burrow refused the gophers", "name", c.burrow.Name, "error", resp.err.Error())
 	}
 
-	return <-respB, <-respErrs
+	return Burrow{}, ErrNoBurrowAvailable
 }
 
-func (m *manager) Report() Report {
+// startPlacing reserves the gophers for a rental. It fails if one of them is being placed by another rental
+func (m *manager) startPlacing(gophers []string) error {
+	m.placingMu.Lock()
+	defer m.placingMu.Unlock()
 
-	rep := Report{}
+	for _, g := range gophers {
+		if m.placing[g] {
+			return fmt.Errorf("%w: %s is being placed", ErrAlreadyHoused, g)
+		}
+	}
+	for _, g := range gophers {
+		m.placing[g] = true
+	}
+	return nil
+}
 
-	burrows := m.CurrentStatus()
+// donePlacing releases the gophers reserved by `startPlacing`
+func (m *manager) donePlacing(gophers []string) {
+	m.placingMu.Lock()
+	defer m.placingMu.Unlock()
 
-	for _, b := range burrows {
-		rep.TotalDepth += b.Depth
+	for _, g := range gophers {
+		delete(m.placing, g)
+	}
+}
 
-		if b.IsAvailable() {
-			rep.NumAvailable++
+// placementOrder sorts burrows in the order in which they should be offered to a family.
+// Partially occupied burrows are filled first so that empty burrows stay free for large families.
+// Between burrows of the same kind the one that fits the family more tightly wins.
+func placementOrder(a, b Burrow) int {
+	if a.IsOccupied() != b.IsOccupied() {
+		if a.IsOccupied() {
+			return -1
 		}
+		return 1
+	}
+	return cmp.Compare(a.FreeSlots(), b.FreeSlots())
+}
 
-		vol := b.Volume()
-		if rep.VolumeMin == 0 || vol < rep.VolumeMin {
-			rep.VolumeMin = vol
-			rep.VolumeMinName = b.Name
-		}
+// newGopherName names an anonymous gopher. The name stays unique across restarts of the server.
+func newGopherName() string {
+	return fmt.Sprintf("gopher-%s-%d", strconv.FormatInt(startedAt, 36), gopherSeq.Add(1))
+}
+
+// SetLabels replaces the labels of the burrow with the given name.
+// It returns the updated burrow or an error if no burrow has that name.
+func (m *manager) SetLabels(name string, labels map[string]string) (Burrow, error) {
+	if err := ValidateLabels(labels); err != nil {
+		return Burrow{}, err
+	}
+
+	ch := make(chan Response)
+	return m.update(NewLabelsRequest(name, labels, ch), ch)
+}
+
+// Vacate lets the gophers move out of the burrow with the given name. Without gophers all the occupants move out.
+// It returns the updated burrow or an error if no burrow has that name or a gopher does not live in it.
+func (m *manager) Vacate(name string, gophers []string) (Burrow, error) {
+	ch := make(chan Response)
+	return m.update(NewVacateRequest(name, gophers, ch), ch)
+}
+
+// Reconcile brings the burrows in line with records of the inventory, like a data file that changed.
+// Records of unknown burrows are added, records of known burrows update the attributes that the inventory
+// owns without disturbing the occupants (see `Burrow.Reconcile`) and records marked as removed decommission their burrow.
+// It returns the names of the burrows that changed.
+func (m *manager) Reconcile(records []Burrow) Reconciliation {
+	req := syncRequest{records: records, response: make(chan Reconciliation, 1)}
+	m.syncs <- req
+	return <-req.response
+}
+
+// update sends the request for a single burrow to all the shards, as only the shard
+// that owns the burrow knows about it. The others respond with `ErrUnknownBurrow`.
+func (m *manager) update(req Request, responses chan Response) (Burrow, error) {
+	count := 0
+	for sh := range m.stream() {
+		count++
+		go func() { sh.requests <- req }()
+	}
 
-		if rep.VolumeMax < vol {
-			rep.VolumeMax = vol
-			rep.VolumeMaxName = b.Name
+	var (
+		updated Burrow
+		found   bool
+	)
+	var err error
+	for range count {
+		resp := <-responses
+		switch {
+		case resp.err == nil:
+			updated, found = resp.burrow, true
+		case !errors.Is(resp.err, ErrUnknownBurrow):
+			err = resp.err
 		}
 	}
 
-	return rep
+	if err != nil {
+		return Burrow{}, err
+	}
+	if !found {
+		return Burrow{}, ErrUnknownBurrow
+	}
+	return updated, nil
+}
+
+// Report summarizes the status of the burrows matched by the selector.
+func (m *manager) Report(sel Selector) Report {
+	burrows := Filter(m.CurrentStatus(), sel)
+	r := NewReport(burrows)
+	r.Taken, r.Burrows = m.clock.Now(), burrows
+	return r
+}
+
+// Forecast predicts the future of all the burrows from now until the horizon.
+// The expected availability is sampled every `step`.
+func (m *manager) Forecast(horizon, step time.Duration) (Forecast, error) {
+	return NewForecast(m.CurrentStatus(), m.clock.Now(), m.Speed(), horizon, step)
 }
 
-// stream returns a channel where it sends all the burrows that
+// stream returns a channel where it sends all the shards that
 // the manager manages at the moment.
 // It is thread safe and meant to be used internally to expose data to other go routines.
-func (m *manager) stream() <-chan managedBurrow {
-	all := make(chan managedBurrow)
+func (m *manager) stream() <-chan shard {
+	all := make(chan shard)
 	m.list <- all
 	return all
 }
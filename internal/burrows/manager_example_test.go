@@ -10,10 +10,20 @@ func ExampleReport_Write() {
 	r := Report{
 		TotalDepth:    10.23434,
 		NumAvailable:  145,
+		FreeSlots:     212,
 		VolumeMin:     34.81231,
 		VolumeMinName: "Burrow 3",
 		VolumeMax:     78.312313,
 		VolumeMaxName: "Burrow 123",
+
+		Count:          150,
+		OccupancyRate:  0.4213,
+		Collapsed:      3,
+		CollapsingSoon: 2,
+		Age:            Distribution{Avg: 7200.4, P50: 6000, P90: 30000, P99: 35000},
+		Depth:          Distribution{Avg: 1.5, P50: 1.25, P90: 3.4, P99: 4.1},
+		Largest:        []RankedBurrow{{Name: "Burrow 123", Volume: 78.312313}},
+		Smallest:       []RankedBurrow{{Name: "Burrow 3", Volume: 34.81231}},
 	}
 
 	w := tabwriter.NewWriter(os.Stdout, 15, 0, 0, '.', tabwriter.AlignRight|tabwriter.Debug)
@@ -23,6 +33,19 @@ func ExampleReport_Write() {
 	// output:
 	// .....TotalDepth|.........10.234|
 	// ...NumAvailable|............145|
+	// ......FreeSlots|............212|
 	// ..VolumeMinName|.......Burrow 3|
 	// ..VolumeMaxName|.....Burrow 123|
+	// ........Burrows|............150|
+	// ..OccupancyRate|..........0.421|
+	// ......Collapsed|..............3|
+	// CollapsingIn24h|..............2|
+	// .........AgeAvg|...........7200|
+	// .........AgeP50|...........6000|
+	// .........AgeP90|..........30000|
+	// .......DepthAvg|..........1.500|
+	// .......DepthP50|..........1.250|
+	// .......DepthP90|..........3.400|
+	// ........Largest|.....Burrow 123|
+	// .......Smallest|.......Burrow 3|
 }
@@ -0,0 +1,345 @@
+package burrows
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPlacementOrder(t *testing.T) {
+
+	candidates := []Burrow{
+		{Name: "empty large", Capacity: 6},
+		{Name: "partial roomy", Capacity: 6, Occupants: []string{"a"}},
+		{Name: "empty small", Capacity: 2},
+		{Name: "partial tight", Capacity: 3, Occupants: []string{"b"}},
+	}
+
+	slices.SortStableFunc(candidates, placementOrder)
+
+	var got []string
+	for _, b := range candidates {
+		got = append(got, b.Name)
+	}
+
+	expected := []string{"partial tight", "partial roomy", "empty small", "empty large"}
+	if !slices.Equal(expected, got) {
+		t.Errorf("wrong placement order. expected: %v, got: %v", expected, got)
+	}
+}
+
+func newTestManager(t testing.TB, clock Clock, opts ...Option) *manager {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	opts = append([]Option{WithClock(clock), WithTact(time.Minute), WithDumpDir(t.TempDir())}, opts...)
+	m := NewManager(ctx, slog.New(slog.NewTextHandler(io.Discard, nil)), opts...)
+	t.Cleanup(func() {
+		cancel()
+		<-m.Done
+	})
+
+	return m
+}
+
+func loadBurrows(m *manager, data ...Burrow) {
+	in := make(chan Burrow)
+	go func() {
+		defer close(in)
+		for _, burrow := range data {
+			in <- burrow
+		}
+	}()
+	m.Load(in)
+}
+
+func generateBurrows(n int) []Burrow {
+	data := make([]Burrow, n)
+	for i := range data {
+		data[i] = Burrow{Name: fmt.Sprintf("burrow %d", i), Capacity: 1 + i%4, Depth: 1, Width: 1, AgeInMin: i % maxAgeInMin}
+		if i%3 == 0 {
+			data[i].Occupants = []string{"gopher"}
+		}
+	}
+	return data
+}
+
+var benchmarkSizes = []int{10_000, 100_000, 1_000_000}
+
+// reportRuntime reports the go routines that are running and the memory of their stacks,
+// which is not part of the allocations
+func reportRuntime(b *testing.B) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	b.ReportMetric(float64(runtime.NumGoroutine()), "goroutines")
+	b.ReportMetric(float64(ms.StackInuse), "stack-B")
+}
+
+// BenchmarkManagerLoad shows the memory needed to manage the burrows.
+func BenchmarkManagerLoad(b *testing.B) {
+	for _, n := range benchmarkSizes {
+		data := generateBurrows(n)
+		b.Run(fmt.Sprintf("burrows=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				ctx, cancel := context.WithCancel(context.Background())
+				m := NewManager(ctx, slog.New(slog.NewTextHandler(io.Discard, nil)), WithClock(NewFakeClock(time.Now())), WithDumpDir(b.TempDir()))
+
+				loadBurrows(m, data...)
+				b.StopTimer()
+				reportRuntime(b)
+				cancel()
+				<-m.Done
+				b.StartTimer()
+			}
+		})
+	}
+}
+
+// BenchmarkGoroutinePerBurrowLoad is the baseline of BenchmarkManagerLoad: every burrow ages in its own
+// go routine with its own ticker, instead of sharing the go routine and the ticker of its shard.
+func BenchmarkGoroutinePerBurrowLoad(b *testing.B) {
+	for _, n := range benchmarkSizes {
+		data := generateBurrows(n)
+		b.Run(fmt.Sprintf("burrows=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				ctx, cancel := context.WithCancel(context.Background())
+				var wg sync.WaitGroup
+				for _, burrow := range data {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						ticker := RealClock.NewTicker(time.Minute)
+						defer ticker.Stop()
+						for {
+							select {
+							case <-ctx.Done():
+								return
+							case <-ticker.C():
+								burrow.IncrementAge()
+							}
+						}
+					}()
+				}
+				b.StopTimer()
+				reportRuntime(b)
+				cancel()
+				wg.Wait()
+				b.StartTimer()
+			}
+		})
+	}
+}
+
+// BenchmarkManagerTick shows the CPU needed to age all the burrows by one minute.
+func BenchmarkManagerTick(b *testing.B) {
+	for _, n := range benchmarkSizes {
+		clock := NewFakeClock(time.Now())
+		m := newTestManager(b, clock)
+		loadBurrows(m, generateBurrows(n)...)
+		b.Run(fmt.Sprintf("burrows=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for range b.N {
+				clock.Advance(time.Minute)
+			}
+			// wait for the last tick to be handled
+			b.StopTimer()
+			_ = m.CurrentStatus()
+		})
+	}
+}
+
+func TestManagerAging(t *testing.T) {
+
+	clock := NewFakeClock(time.Now())
+	m := newTestManager(t, clock)
+	loadBurrows(m,
+		Burrow{Name: "free", Depth: 1, AgeInMin: 10},
+		Burrow{Name: "occupied", Depth: 1, AgeInMin: 10, Occupants: []string{"gopher"}},
+		Burrow{Name: "about to collapse", Depth: 1, AgeInMin: maxAgeInMin - 5},
+	)
+
+	clock.Advance(60 * time.Minute)
+
+	for _, b := range m.CurrentStatus() {
+		switch b.Name {
+		case "free":
+			if b.AgeInMin != 70 || b.Depth != 1 {
+				t.Errorf("free burrow should only age. got: %+v", b)
+			}
+		case "occupied":
+			if b.AgeInMin != 70 || math.Abs(b.Depth-math.Pow(1.009, 60)) > 0.0001 {
+				t.Errorf("occupied burrow should age and grow deeper. got: %+v", b)
+			}
+		case "about to collapse":
+			if !b.IsCollapsed() || b.IsAvailable() {
+				t.Errorf("burrow should have collapsed. got: %+v", b)
+			}
+		}
+	}
+}
+
+func TestManagerLoadRemoved(t *testing.T) {
+
+	data := "name,capacity,removed\nkept,1,false\ngone,1,true\nlast,2,\n"
+	dec, err := NewDecoder(strings.NewReader(data), FormatCSV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := make(chan Burrow)
+	go func() {
+		defer close(in)
+		for {
+			b, err := dec.Next()
+			if err != nil {
+				return
+			}
+			in <- b
+		}
+	}()
+
+	m := newTestManager(t, NewFakeClock(time.Now()))
+	m.Load(in)
+
+	var names []string
+	for _, b := range m.CurrentStatus() {
+		names = append(names, b.Name)
+	}
+	if expected := []string{"kept", "last"}; !slices.Equal(expected, names) {
+		t.Errorf("decommissioned burrows should not be loaded. expected: %v, got: %v", expected, names)
+	}
+}
+
+func TestManagerRentout(t *testing.T) {
+
+	m := newTestManager(t, NewFakeClock(time.Now()))
+	loadBurrows(m,
+		Burrow{Name: "empty", Capacity: 3, Labels: map[string]string{"site": "north"}},
+		Burrow{Name: "partial", Capacity: 3, Occupants: []string{"a"}, Labels: map[string]string{"site": "north"}},
+		Burrow{Name: "south", Capacity: 5, Labels: map[string]string{"site": "south"}},
+	)
+
+	north, _ := ParseSelector("site=north")
+
+	scenarios := []struct {
+		rental Rental
+		burrow string // expected burrow or empty if the rental should fail
+	}{
+		{rental: Rental{Gophers: []string{"b", "c"}, Selector: north}, burrow: "partial"},
+		{rental: Rental{Gophers: []string{"d", "e"}, Selector: north}, burrow: "empty"},
+		{rental: Rental{Gophers: []string{"f", "g"}, Selector: north}, burrow: ""},
+		{rental: Rental{}, burrow: "empty"},
+		{rental: Rental{Gophers: []string{"h", "i", "j", "k", "l"}}, burrow: "south"},
+	}
+
+	for _, s := range scenarios {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		b, err := m.Rentout(ctx, s.rental)
+		cancel()
+
+		if s.burrow == "" {
+			if err == nil {
+				t.Errorf("rental %v should fail. got: %v", s.rental, b)
+			}
+			continue
+		}
+
+		if err != nil || b.Name != s.burrow {
+			t.Errorf("rental %v should move into %s. got: %v, error: %v", s.rental, s.burrow, b, err)
+		}
+	}
+}
+
+func TestManagerRentoutHoused(t *testing.T) {
+
+	m := newTestManager(t, NewFakeClock(time.Now()))
+	loadBurrows(m,
+		Burrow{Name: "home", Capacity: 2, Occupants: []string{"a"}},
+		Burrow{Name: "other", Capacity: 3},
+	)
+
+	ctx := context.Background()
+	if b, err := m.Rentout(ctx, Rental{Gophers: []string{"b", "a"}}); !errors.Is(err, ErrAlreadyHoused) {
+		t.Errorf("a gopher that lives in a burrow should not move into another one. got: %v, error: %v", b, err)
+	}
+
+	// only one of the concurrent rentals of the same gopher moves in
+	var wg sync.WaitGroup
+	var housed atomic.Int32
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := m.Rentout(ctx, Rental{Gophers: []string{"c"}}); err == nil {
+				housed.Add(1)
+			} else if !errors.Is(err, ErrAlreadyHoused) {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := housed.Load(); n != 1 {
+		t.Errorf("the gopher should be housed once. got: %d", n)
+	}
+}
+
+func TestManagerSpeed(t *testing.T) {
+
+	clock := NewFakeClock(time.Now())
+	m := newTestManager(t, clock)
+	loadBurrows(m, Burrow{Name: "burrow", Depth: 1})
+
+	age := func() int { return m.CurrentStatus()[0].AgeInMin }
+
+	if s := m.Pause(); !s.Paused || s.Tact != time.Minute {
+		t.Errorf("wrong speed after pausing: %+v", s)
+	}
+	clock.Advance(10 * time.Minute)
+	if a := age(); a != 0 {
+		t.Errorf("paused burrows should not age. got: %d", a)
+	}
+
+	if _, err := m.Advance(2 * time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if a := age(); a != 120 {
+		t.Errorf("burrows should be fast-forwarded while paused. expected: 120, got: %d", a)
+	}
+	if f, err := m.Forecast(24*time.Hour, time.Hour); err != nil || !f.Paused || !f.Burrows[0].CollapsesAt.IsZero() {
+		t.Errorf("the forecast should be flat while paused. got: %+v, %v", f, err)
+	}
+
+	if s := m.Resume(); s.Paused {
+		t.Errorf("wrong speed after resuming: %+v", s)
+	}
+	clock.Advance(10 * time.Minute)
+	if a := age(); a != 130 {
+		t.Errorf("resumed burrows should age. expected: 130, got: %d", a)
+	}
+
+	if _, err := m.SetTact(2 * time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(10 * time.Minute)
+	if a := age(); a != 135 {
+		t.Errorf("burrows should age slower. expected: 135, got: %d", a)
+	}
+
+	if _, err := m.SetTact(0); err == nil {
+		t.Error("expected an error for an empty tact")
+	}
+	if s := m.Speed(); s.Tact != 2*time.Minute || s.Paused {
+		t.Errorf("an invalid change should not change the speed. got: %+v", s)
+	}
+}
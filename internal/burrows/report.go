@@ -0,0 +1,217 @@
+package burrows
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ReportTopN is the number of largest and smallest burrows listed in a report
+const ReportTopN = 5
+
+// VolumeBuckets are the upper bounds of the volume histogram of a report.
+var VolumeBuckets = []float64{0.5, 1, 2, 4, 8}
+
+// Report summarizes the status of the burrows.
+type Report struct {
+	// Taken is when the burrows were in this state, zero if unknown
+	Taken         time.Time `json:"taken"`
+	TotalDepth    float64   `json:"totalDepth"`
+	NumAvailable  int       `json:"numAvailable"`
+	FreeSlots     int       `json:"freeSlots"`
+	VolumeMin     float64   `json:"volumeMin"`
+	VolumeMinName string    `json:"volumeMinName"`
+	VolumeMax     float64   `json:"volumeMax"`
+	VolumeMaxName string    `json:"volumeMaxName"`
+
+	// Count is the number of burrows
+	Count int `json:"count"`
+	// Slots is the number of gophers all the burrows can host, Occupants the number that live in them
+	Slots     int `json:"slots"`
+	Occupants int `json:"occupants"`
+	// OccupancyRate is the share of the slots taken by gophers, between 0 and 1
+	OccupancyRate float64 `json:"occupancyRate"`
+	Collapsed     int     `json:"collapsed"`
+	// CollapsingSoon is the number of standing burrows that collapse within the next 24 hours of their life
+	CollapsingSoon int `json:"collapsingWithin24h"`
+	// Age is the distribution of the ages in minutes
+	Age   Distribution `json:"age"`
+	Depth Distribution `json:"depth"`
+	// TotalVolume is the sum of the volumes, the volume histogram counts the burrows by volume
+	TotalVolume     float64   `json:"totalVolume"`
+	VolumeHistogram Histogram `json:"volumeHistogram"`
+	// Largest and Smallest are the burrows with the largest and the smallest volumes, up to `ReportTopN` of each
+	Largest  []RankedBurrow `json:"largest"`
+	Smallest []RankedBurrow `json:"smallest"`
+
+	// Burrows are the burrows the manager reported on, for the report templates. They are not encoded by the other formats
+	Burrows []Burrow `json:"-"`
+}
+
+// Distribution summarizes a set of values by their average and percentiles.
+type Distribution struct {
+	Avg float64 `json:"avg"`
+	P50 float64 `json:"p50"`
+	P90 float64 `json:"p90"`
+	P99 float64 `json:"p99"`
+}
+
+// Histogram counts values by buckets. Counts[i] is the number of values below Bounds[i]
+// and not below the previous bound. The last count is the number of values not below the last bound.
+type Histogram struct {
+	Bounds []float64 `json:"bounds"`
+	Counts []int     `json:"counts"`
+}
+
+// RankedBurrow is a burrow of a top list of a report.
+type RankedBurrow struct {
+	Name   string  `json:"name"`
+	Volume float64 `json:"volume"`
+}
+
+func (r Report) Write(w io.Writer) error {
+
+	txt := `TotalDepth	%.3f	
+NumAvailable	%d	
+FreeSlots	%d	
+VolumeMinName	%s	
+VolumeMaxName	%s	
+`
+
+	_, err := fmt.Fprintf(w, txt, r.TotalDepth, r.NumAvailable, r.FreeSlots, r.VolumeMinName, r.VolumeMaxName)
+	if err != nil {
+		return err
+	}
+
+	names := func(ranked []RankedBurrow) string {
+		s := make([]string, len(ranked))
+		for i, b := range ranked {
+			s[i] = b.Name
+		}
+		return strings.Join(s, ", ")
+	}
+
+	txt = `Burrows	%d	
+OccupancyRate	%.3f	
+Collapsed	%d	
+CollapsingIn24h	%d	
+AgeAvg	%.0f	
+AgeP50	%.0f	
+AgeP90	%.0f	
+DepthAvg	%.3f	
+DepthP50	%.3f	
+DepthP90	%.3f	
+Largest	%s	
+Smallest	%s	
+`
+
+	_, err = fmt.Fprintf(w, txt, r.Count, r.OccupancyRate, r.Collapsed, r.CollapsingSoon,
+		r.Age.Avg, r.Age.P50, r.Age.P90, r.Depth.Avg, r.Depth.P50, r.Depth.P90,
+		names(r.Largest), names(r.Smallest))
+
+	return err
+}
+
+// NewReport summarizes the status of the burrows in a single pass over them.
+func NewReport(burrows []Burrow) Report {
+
+	rep := Report{
+		Count:           len(burrows),
+		VolumeHistogram: Histogram{Bounds: slices.Clone(VolumeBuckets), Counts: make([]int, len(VolumeBuckets)+1)},
+	}
+	ages := make([]float64, 0, len(burrows))
+	depths := make([]float64, 0, len(burrows))
+
+	for _, b := range burrows {
+		rep.TotalDepth += b.Depth
+
+		if b.IsAvailable() {
+			rep.NumAvailable++
+		}
+		rep.FreeSlots += b.FreeSlots()
+
+		vol := b.Volume()
+		if rep.VolumeMin == 0 || vol < rep.VolumeMin {
+			rep.VolumeMin = vol
+			rep.VolumeMinName = b.Name
+		}
+
+		if rep.VolumeMax < vol {
+			rep.VolumeMax = vol
+			rep.VolumeMaxName = b.Name
+		}
+
+		rep.Slots += b.Slots()
+		rep.Occupants += len(b.Occupants)
+		if b.IsCollapsed() {
+			rep.Collapsed++
+		} else if maxAgeInMin-b.AgeInMin <= 24*60 {
+			rep.CollapsingSoon++
+		}
+		ages = append(ages, float64(b.AgeInMin))
+		depths = append(depths, b.Depth)
+
+		rep.TotalVolume += vol
+		bucket := 0
+		for bucket < len(VolumeBuckets) && vol >= VolumeBuckets[bucket] {
+			bucket++
+		}
+		rep.VolumeHistogram.Counts[bucket]++
+
+		ranked := RankedBurrow{Name: b.Name, Volume: vol}
+		rep.Largest = insertRanked(rep.Largest, ranked, func(a, b float64) bool { return a > b })
+		rep.Smallest = insertRanked(rep.Smallest, ranked, func(a, b float64) bool { return a < b })
+	}
+
+	if rep.Slots > 0 {
+		rep.OccupancyRate = float64(rep.Occupants) / float64(rep.Slots)
+	}
+	rep.Age = newDistribution(ages)
+	rep.Depth = newDistribution(depths)
+
+	return rep
+}
+
+// insertRanked keeps the top list sorted and no longer than `ReportTopN`.
+// A burrow only ranks before the ones that it beats, so the first of equal burrows stays first.
+func insertRanked(top []RankedBurrow, b RankedBurrow, beats func(a, b float64) bool) []RankedBurrow {
+	i := len(top)
+	for i > 0 && beats(b.Volume, top[i-1].Volume) {
+		i--
+	}
+	if i >= ReportTopN {
+		return top
+	}
+	top = slices.Insert(top, i, b)
+	if len(top) > ReportTopN {
+		top = top[:ReportTopN]
+	}
+	return top
+}
+
+// newDistribution sorts the values in place. Percentiles follow the nearest-rank method
+func newDistribution(values []float64) Distribution {
+	if len(values) == 0 {
+		return Distribution{}
+	}
+	slices.Sort(values)
+
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	percentile := func(p float64) float64 {
+		rank := int(math.Ceil(p * float64(len(values))))
+		return values[max(rank-1, 0)]
+	}
+
+	return Distribution{
+		Avg: sum / float64(len(values)),
+		P50: percentile(0.50),
+		P90: percentile(0.90),
+		P99: percentile(0.99),
+	}
+}
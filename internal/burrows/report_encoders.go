@@ -0,0 +1,295 @@
+package burrows
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReportEncoder writes a report in one format.
+type ReportEncoder interface {
+	Encode(w io.Writer, r Report) error
+	// ContentType is the media type of the encoded report
+	ContentType() string
+	// Extension of the report files, without the dot
+	Extension() string
+}
+
+var (
+	reportEncodersMu sync.RWMutex
+	reportEncoders   = map[string]ReportEncoder{
+		"text":       textReportEncoder{},
+		"json":       jsonReportEncoder{},
+		"csv":        csvReportEncoder{},
+		"markdown":   markdownReportEncoder{},
+		"html":       htmlReportEncoder{},
+		"prometheus": prometheusReportEncoder{},
+	}
+)
+
+// RegisterReportEncoder makes a report format available under the given name, replacing the encoder
+// that had the name before. The built-in formats are text, json, csv, markdown, html and prometheus.
+func RegisterReportEncoder(name string, e ReportEncoder) {
+	reportEncodersMu.Lock()
+	defer reportEncodersMu.Unlock()
+	reportEncoders[strings.ToLower(name)] = e
+}
+
+// LookupReportEncoder returns the encoder of the report format with the given name.
+func LookupReportEncoder(name string) (ReportEncoder, error) {
+	reportEncodersMu.RLock()
+	defer reportEncodersMu.RUnlock()
+	e, ok := reportEncoders[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown report format: %s", name)
+	}
+	return e, nil
+}
+
+// ReportFormats returns the names of all the report formats, sorted.
+func ReportFormats() []string {
+	reportEncodersMu.RLock()
+	defer reportEncodersMu.RUnlock()
+	names := make([]string, 0, len(reportEncoders))
+	for name := range reportEncoders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// reportMetric is one value of a report, in the order in which the formats list them
+type reportMetric struct {
+	// family groups the values of a metric, like the buckets of a histogram. It defaults to the name
+	family string
+	// typ is the type of the family in the exposition format, gauge by default
+	typ   string
+	help  string
+	name  string
+	value string
+	// label tells apart the values of a family, ex: le="1"
+	label, key string
+	// named values belong to a burrow, the burrow is its name
+	named  bool
+	burrow string
+}
+
+// id names the value in formats without labels
+func (m reportMetric) id() string {
+	if m.label == "" {
+		return m.name
+	}
+	return m.name + "_" + m.label + "_" + m.key
+}
+
+func (r Report) metrics() []reportMetric {
+	float := func(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) }
+	metrics := []reportMetric{
+		{name: "total_depth", help: "Sum of the depths of the burrows.", value: float(r.TotalDepth)},
+		{name: "available", help: "Number of burrows with free slots.", value: strconv.Itoa(r.NumAvailable)},
+		{name: "free_slots", help: "Number of gophers that can still move in.", value: strconv.Itoa(r.FreeSlots)},
+		{name: "volume_min", help: "Volume of the smallest burrow.", value: float(r.VolumeMin), named: true, burrow: r.VolumeMinName},
+		{name: "volume_max", help: "Volume of the largest burrow.", value: float(r.VolumeMax), named: true, burrow: r.VolumeMaxName},
+		{name: "count", help: "Number of burrows.", value: strconv.Itoa(r.Count)},
+		{name: "slots", help: "Number of gophers the burrows can host.", value: strconv.Itoa(r.Slots)},
+		{name: "occupants", help: "Number of gophers living in the burrows.", value: strconv.Itoa(r.Occupants)},
+		{name: "occupancy_rate", help: "Share of the slots taken by gophers.", value: float(r.OccupancyRate)},
+		{name: "collapsed", help: "Number of collapsed burrows.", value: strconv.Itoa(r.Collapsed)},
+		{name: "collapsing_24h", help: "Number of burrows that collapse within the next 24 hours of their life.", value: strconv.Itoa(r.CollapsingSoon)},
+	}
+
+	for _, d := range []struct {
+		name, unit string
+		dist       Distribution
+	}{{name: "age", unit: " in minutes", dist: r.Age}, {name: "depth", dist: r.Depth}} {
+		for _, stat := range []struct {
+			name, prefix string
+			value        float64
+		}{
+			{name: "avg", prefix: "Average", value: d.dist.Avg},
+			{name: "p50", prefix: "Median", value: d.dist.P50},
+			{name: "p90", prefix: "90th percentile of the", value: d.dist.P90},
+			{name: "p99", prefix: "99th percentile of the", value: d.dist.P99},
+		} {
+			help := fmt.Sprintf("%s %s of the burrows%s.", stat.prefix, d.name, d.unit)
+			metrics = append(metrics, reportMetric{name: d.name + "_" + stat.name, help: help, value: float(stat.value)})
+		}
+	}
+
+	// the exposition format counts the buckets of a histogram cumulatively
+	const volumeHelp = "Volumes of the burrows."
+	cumulative := 0
+	for i, count := range r.VolumeHistogram.Counts {
+		cumulative += count
+		le := "+Inf"
+		if i < len(r.VolumeHistogram.Bounds) {
+			le = float(r.VolumeHistogram.Bounds[i])
+		}
+		metrics = append(metrics, reportMetric{family: "volume", typ: "histogram", help: volumeHelp, name: "volume_bucket", label: "le", key: le, value: strconv.Itoa(cumulative)})
+	}
+	metrics = append(metrics,
+		reportMetric{family: "volume", typ: "histogram", help: volumeHelp, name: "volume_sum", value: float(r.TotalVolume)},
+		reportMetric{family: "volume", typ: "histogram", help: volumeHelp, name: "volume_count", value: strconv.Itoa(cumulative)},
+	)
+
+	for _, top := range []struct {
+		name, help string
+		burrows    []RankedBurrow
+	}{
+		{name: "largest_volume", help: "Volumes of the largest burrows.", burrows: r.Largest},
+		{name: "smallest_volume", help: "Volumes of the smallest burrows.", burrows: r.Smallest},
+	} {
+		for i, b := range top.burrows {
+			metrics = append(metrics, reportMetric{name: top.name, help: top.help, label: "rank", key: strconv.Itoa(i + 1), value: float(b.Volume), named: true, burrow: b.Name})
+		}
+	}
+
+	return metrics
+}
+
+// textReportEncoder is the tab separated layout of `Report.Write`
+type textReportEncoder struct{}
+
+func (textReportEncoder) Encode(w io.Writer, r Report) error { return r.Write(w) }
+func (textReportEncoder) ContentType() string                { return "text/plain; charset=utf-8" }
+func (textReportEncoder) Extension() string                  { return "txt" }
+
+type jsonReportEncoder struct{}
+
+func (jsonReportEncoder) Encode(w io.Writer, r Report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+func (jsonReportEncoder) ContentType() string { return "application/json" }
+func (jsonReportEncoder) Extension() string   { return "json" }
+
+// csvReportEncoder writes a header and a single row, so that the reports of several files can be concatenated
+type csvReportEncoder struct{}
+
+func (csvReportEncoder) Encode(w io.Writer, r Report) error {
+	header, row := []string{"taken"}, []string{formatTaken(r.Taken)}
+	for _, m := range r.metrics() {
+		header, row = append(header, m.id()), append(row, m.value)
+		if m.named {
+			header, row = append(header, m.id()+"_name"), append(row, m.burrow)
+		}
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.WriteAll([][]string{header, row}); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+func (csvReportEncoder) ContentType() string { return "text/csv" }
+func (csvReportEncoder) Extension() string   { return "csv" }
+
+type markdownReportEncoder struct{}
+
+func (markdownReportEncoder) Encode(w io.Writer, r Report) error {
+	var b strings.Builder
+	b.WriteString("# Burrows report\n\n")
+	if !r.Taken.IsZero() {
+		fmt.Fprintf(&b, "Taken at %s\n\n", formatTaken(r.Taken))
+	}
+	b.WriteString("| Metric | Value | Burrow |\n|---|---:|---|\n")
+	for _, m := range r.metrics() {
+		fmt.Fprintf(&b, "| %s | %s | %s |\n", m.id(), m.value, strings.ReplaceAll(m.burrow, "|", `\|`))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+func (markdownReportEncoder) ContentType() string { return "text/markdown; charset=utf-8" }
+func (markdownReportEncoder) Extension() string   { return "md" }
+
+var htmlReport = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Burrows report</title></head>
+<body>
+<h1>Burrows report</h1>
+{{if .Taken}}<p>Taken at {{.Taken}}</p>
+{{end}}<table>
+<tr><th>Metric</th><th>Value</th><th>Burrow</th></tr>
+{{range .Metrics}}<tr><td>{{.Name}}</td><td>{{.Value}}</td><td>{{.Label}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+type htmlReportEncoder struct{}
+
+func (htmlReportEncoder) Encode(w io.Writer, r Report) error {
+	type metric struct{ Name, Value, Label string }
+	data := struct {
+		Taken   string
+		Metrics []metric
+	}{}
+	if !r.Taken.IsZero() {
+		data.Taken = formatTaken(r.Taken)
+	}
+	for _, m := range r.metrics() {
+		data.Metrics = append(data.Metrics, metric{Name: m.id(), Value: m.value, Label: m.burrow})
+	}
+	return htmlReport.Execute(w, data)
+}
+func (htmlReportEncoder) ContentType() string { return "text/html; charset=utf-8" }
+func (htmlReportEncoder) Extension() string   { return "html" }
+
+// prometheusEscaper escapes label values as the exposition format expects
+var prometheusEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// prometheusReportEncoder writes the text exposition format, every metric is named `burrows_<metric>`.
+// The volumes are a histogram, all the other metrics are gauges. The burrow of a value is in the `name` label.
+type prometheusReportEncoder struct{}
+
+func (prometheusReportEncoder) Encode(w io.Writer, r Report) error {
+	var b strings.Builder
+	family := ""
+	for _, m := range r.metrics() {
+		if m.family == "" {
+			m.family = m.name
+		}
+		if m.typ == "" {
+			m.typ = "gauge"
+		}
+		if m.family != family {
+			family = m.family
+			fmt.Fprintf(&b, "# HELP burrows_%s %s\n# TYPE burrows_%s %s\n", family, m.help, family, m.typ)
+		}
+
+		var labels []string
+		if m.label != "" {
+			labels = append(labels, fmt.Sprintf("%s=\"%s\"", m.label, m.key))
+		}
+		if m.named {
+			labels = append(labels, fmt.Sprintf("name=\"%s\"", prometheusEscaper.Replace(m.burrow)))
+		}
+		if len(labels) > 0 {
+			fmt.Fprintf(&b, "burrows_%s{%s} %s\n", m.name, strings.Join(labels, ","), m.value)
+		} else {
+			fmt.Fprintf(&b, "burrows_%s %s\n", m.name, m.value)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (prometheusReportEncoder) ContentType() string {
+	return "text/plain; version=0.0.4; charset=utf-8"
+}
+func (prometheusReportEncoder) Extension() string { return "prom" }
+
+func formatTaken(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,253 @@
+package burrows
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// reportTimeFormat and rollupTimeFormat are part of the names of the reports and of the daily rollups
+	reportTimeFormat = "20060102_150405"
+	rollupTimeFormat = "20060102"
+	// rollupExtension is the extension of the daily rollups, they have one JSON report per line
+	rollupExtension = "ndjson"
+)
+
+// ReportFiles are the periodic reports kept in a directory, in the local time zone.
+// Every report is named after the time it was taken, ex: `burrows_20240301_100000.txt`,
+// and the daily rollups after their day, ex: `burrows_20240301.ndjson`.
+// The reports of a schedule also have its name, ex: `burrows_hourly_20240301_100000.txt`, so that
+// schedules can share a directory: they never overwrite nor remove the files of each other.
+type ReportFiles struct {
+	Dir string
+	// Schedule is the name of the schedule of the reports, if any. `MaxSize` only counts its files
+	Schedule string
+	// Keep is the number of reports to keep, every report counts once whatever the number of its formats. 0 keeps all of them
+	Keep int
+	// MaxAge is how long a report is kept. 0 keeps them forever
+	MaxAge time.Duration
+	// MaxSize limits the bytes of all the files, rollups included. The oldest files are removed first. 0 is no limit
+	MaxSize int64
+	// CompressAfter is when the reports are gzipped, and the rollups after the end of their day. 0 never compresses them
+	CompressAfter time.Duration
+	// Rollup appends every report to the rollup of its day. Rollups are only removed to fit into `MaxSize`
+	Rollup bool
+}
+
+// ReportFile is a report or a daily rollup in the report directory.
+type ReportFile struct {
+	Path string
+	// Taken is when the report was taken, or the start of the day of a rollup
+	Taken      time.Time
+	Rollup     bool
+	Compressed bool
+	Size       int64
+}
+
+// Write stores the report in every format, and in the rollup of its day. It returns the paths of the reports.
+func (f ReportFiles) Write(r Report, encoders []ReportEncoder) ([]string, error) {
+	taken := r.Taken.In(time.Local)
+	name := f.prefix() + taken.Format(reportTimeFormat)
+
+	var paths []string
+	for _, enc := range encoders {
+		path := filepath.Join(f.Dir, name+"."+enc.Extension())
+		if err := writeFileAtomic(path, func(w io.Writer) error { return enc.Encode(w, r) }); err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+
+	if f.Rollup {
+		if err := f.appendRollup(taken, r); err != nil {
+			return paths, err
+		}
+	}
+	return paths, nil
+}
+
+func (f ReportFiles) appendRollup(taken time.Time, r Report) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(f.Dir, f.prefix()+taken.Format(rollupTimeFormat)+"."+rollupExtension)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o664)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// prefix starts the names of the files, before their time
+func (f ReportFiles) prefix() string {
+	if f.Schedule == "" {
+		return "burrows_"
+	}
+	return "burrows_" + f.Schedule + "_"
+}
+
+// List returns the reports and rollups in the directory, the newest first.
+// Files that are not named like a report of the schedule are ignored.
+func (f ReportFiles) List() ([]ReportFile, error) {
+	entries, err := os.ReadDir(f.Dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var files []ReportFile
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, f.prefix()) {
+			continue
+		}
+
+		file := ReportFile{Path: filepath.Join(f.Dir, name)}
+		name, file.Compressed = strings.CutSuffix(name, ".gz")
+		stem, ext, ok := strings.Cut(strings.TrimPrefix(name, f.prefix()), ".")
+		if !ok || strings.HasSuffix(ext, ".tmp") {
+			continue
+		}
+
+		if taken, err := time.ParseInLocation(reportTimeFormat, stem, time.Local); err == nil {
+			file.Taken = taken
+		} else if day, err := time.ParseInLocation(rollupTimeFormat, stem, time.Local); err == nil && ext == rollupExtension {
+			file.Taken, file.Rollup = day, true
+		} else {
+			continue
+		}
+
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		file.Size = info.Size()
+		files = append(files, file)
+	}
+	slices.SortStableFunc(files, func(a, b ReportFile) int { return b.Taken.Compare(a.Taken) })
+
+	return files, nil
+}
+
+// Compress gzips the reports that are older than `CompressAfter`, and the rollups of the days that ended before.
+// It returns the paths of the compressed files.
+func (f ReportFiles) Compress(now time.Time) ([]string, error) {
+	if f.CompressAfter <= 0 {
+		return nil, nil
+	}
+	files, err := f.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var compressed []string
+	for _, file := range files {
+		end := file.Taken
+		if file.Rollup {
+			end = file.Taken.AddDate(0, 0, 1)
+		}
+		if file.Compressed || now.Sub(end) < f.CompressAfter {
+			continue
+		}
+		if err := gzipFile(file.Path); err != nil {
+			return compressed, err
+		}
+		compressed = append(compressed, file.Path+".gz")
+	}
+
+	return compressed, nil
+}
+
+// gzipFile replaces the file with its compressed version, suffixed by `.gz`
+func gzipFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	err = writeFileAtomic(path+".gz", func(w io.Writer) error {
+		zw := gzip.NewWriter(w)
+		zw.Name = filepath.Base(path)
+		if _, err := io.Copy(zw, src); err != nil {
+			return err
+		}
+		return zw.Close()
+	})
+	if err != nil {
+		return err
+	}
+	src.Close()
+
+	return os.Remove(path)
+}
+
+// Prune removes the reports that are not retained anymore, then the oldest files until they fit into `MaxSize`.
+// It returns the paths of the removed files. The files of the newest report are always kept.
+func (f ReportFiles) Prune(now time.Time) ([]string, error) {
+	files, err := f.List()
+	if err != nil {
+		return nil, err
+	}
+
+	var newest time.Time
+	for _, file := range files {
+		if !file.Rollup {
+			newest = file.Taken
+			break
+		}
+	}
+
+	var removed []string
+	var kept []ReportFile
+	var size int64
+	var last time.Time
+	reports := 0
+	for _, file := range files {
+		if !file.Rollup && !file.Taken.Equal(last) {
+			reports, last = reports+1, file.Taken
+		}
+		tooMany := f.Keep > 0 && reports > f.Keep
+		tooOld := f.MaxAge > 0 && now.Sub(file.Taken) > f.MaxAge
+		if file.Rollup || file.Taken.Equal(newest) || (!tooMany && !tooOld) {
+			kept = append(kept, file)
+			size += file.Size
+			continue
+		}
+		if err := os.Remove(file.Path); err != nil {
+			return removed, err
+		}
+		removed = append(removed, file.Path)
+	}
+
+	// the oldest files go first
+	for i := len(kept) - 1; i >= 0 && f.MaxSize > 0 && size > f.MaxSize; i-- {
+		if !kept[i].Rollup && kept[i].Taken.Equal(newest) {
+			continue
+		}
+		if err := os.Remove(kept[i].Path); err != nil {
+			return removed, err
+		}
+		removed = append(removed, kept[i].Path)
+		size -= kept[i].Size
+	}
+
+	return removed, nil
+}
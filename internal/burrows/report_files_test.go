@@ -0,0 +1,149 @@
+package burrows
+
+import (
+	"bufio"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestReports writes a report every hour, in the text and json formats
+func writeTestReports(t *testing.T, files ReportFiles, start time.Time, n int) {
+	t.Helper()
+	text, _ := LookupReportEncoder("text")
+	js, _ := LookupReportEncoder("json")
+	for i := range n {
+		r := testReport
+		r.Taken = start.Add(time.Duration(i) * time.Hour)
+		if _, err := files.Write(r, []ReportEncoder{text, js}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestReportFilesWrite(t *testing.T) {
+
+	files := ReportFiles{Dir: t.TempDir(), Rollup: true}
+	start := time.Date(2024, 3, 1, 22, 0, 0, 0, time.Local)
+	writeTestReports(t, files, start, 3)
+
+	list, err := files.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range list {
+		names = append(names, filepath.Base(f.Path))
+	}
+	expected := "burrows_20240302.ndjson burrows_20240302_000000.json burrows_20240302_000000.txt " +
+		"burrows_20240301_230000.json burrows_20240301_230000.txt burrows_20240301_220000.json burrows_20240301_220000.txt burrows_20240301.ndjson"
+	if strings.Join(names, " ") != expected {
+		t.Errorf("wrong report files, the newest first.\nexpected: %s\ngot:      %s", expected, strings.Join(names, " "))
+	}
+
+	f, err := os.Open(filepath.Join(files.Dir, "burrows_20240301.ndjson"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	lines := 0
+	for sc := bufio.NewScanner(f); sc.Scan(); {
+		lines++
+	}
+	if lines != 2 {
+		t.Errorf("the rollup should have a report per line. expected: 2, got: %d", lines)
+	}
+}
+
+func TestReportFilesCompress(t *testing.T) {
+
+	files := ReportFiles{Dir: t.TempDir(), Rollup: true, CompressAfter: 90 * time.Minute}
+	start := time.Date(2024, 3, 1, 22, 0, 0, 0, time.Local)
+	writeTestReports(t, files, start, 4)
+
+	compressed, err := files.Compress(start.Add(4 * time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the reports of 22:00, 23:00 and midnight in both formats and the rollup of March 1st
+	if len(compressed) != 7 {
+		t.Errorf("wrong compressed files. got: %v", compressed)
+	}
+
+	list, _ := files.List()
+	for _, f := range list {
+		if f.Compressed != strings.HasSuffix(f.Path, ".gz") {
+			t.Errorf("wrong compression of %s", f.Path)
+		}
+	}
+
+	f, err := os.Open(filepath.Join(files.Dir, "burrows_20240301_220000.txt.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc := bufio.NewScanner(zr); !sc.Scan() || !strings.HasPrefix(sc.Text(), "TotalDepth") {
+		t.Errorf("the compressed report should have the same content")
+	}
+	if _, err := os.Stat(filepath.Join(files.Dir, "burrows_20240301_220000.txt")); !os.IsNotExist(err) {
+		t.Errorf("the uncompressed report should be removed. got: %v", err)
+	}
+}
+
+func TestReportFilesPrune(t *testing.T) {
+
+	start := time.Date(2024, 3, 1, 10, 0, 0, 0, time.Local)
+
+	scenarios := []struct {
+		name  string
+		files ReportFiles
+		// expected number of reports, in both formats, and of rollups left
+		reports, rollups int
+	}{
+		{name: "keep all", reports: 5, rollups: 1},
+		{name: "by count", files: ReportFiles{Keep: 2}, reports: 2, rollups: 1},
+		{name: "by age", files: ReportFiles{MaxAge: 90 * time.Minute}, reports: 2, rollups: 1},
+		{name: "newest is never too old", files: ReportFiles{MaxAge: time.Minute}, reports: 1, rollups: 1},
+		{name: "by size", files: ReportFiles{MaxSize: 1}, reports: 1, rollups: 0},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			t.Parallel()
+
+			files := s.files
+			files.Dir, files.Rollup = t.TempDir(), true
+			writeTestReports(t, files, start, 5)
+
+			if _, err := files.Prune(start.Add(4 * time.Hour)); err != nil {
+				t.Fatal(err)
+			}
+
+			left, err := files.List()
+			if err != nil {
+				t.Fatal(err)
+			}
+			reports, rollups := 0, 0
+			for _, f := range left {
+				if f.Rollup {
+					rollups++
+				} else {
+					reports++
+				}
+			}
+			if reports != 2*s.reports || rollups != s.rollups {
+				t.Fatalf("wrong files left. expected %d reports and %d rollups, got: %v", s.reports, s.rollups, left)
+			}
+			if !left[0].Taken.Equal(start.Add(4 * time.Hour)) {
+				t.Errorf("the newest report should be kept. got: %v", left)
+			}
+		})
+	}
+}
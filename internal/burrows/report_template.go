@@ -0,0 +1,130 @@
+package burrows
+
+import (
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+	"time"
+)
+
+// ReportTemplateFuncs are the helper functions available to the report templates:
+//
+//   - `volume` formats a volume with two decimals, ex: `{{volume .VolumeMax}}`
+//   - `percent` formats a share between 0 and 1, ex: `{{percent .OccupancyRate}}` is `42.1%`
+//   - `duration` formats a time.Duration or minutes in the life of a burrow, ex: `{{duration .AgeInMin}}` is `2d3h`
+//   - `join` joins a list, ex: `{{join .Occupants ", "}}`
+var ReportTemplateFuncs = map[string]any{
+	"volume":  func(v float64) string { return strconv.FormatFloat(v, 'f', 2, 64) },
+	"percent": func(v float64) string { return strconv.FormatFloat(v*100, 'f', 1, 64) + "%" },
+	"duration": func(v any) (string, error) {
+		switch d := v.(type) {
+		case time.Duration:
+			return FormatDuration(d), nil
+		case int:
+			return FormatDuration(time.Duration(d) * time.Minute), nil
+		case float64:
+			return FormatDuration(time.Duration(d * float64(time.Minute))), nil
+		default:
+			return "", fmt.Errorf("duration of a %T", v)
+		}
+	},
+	"join": strings.Join,
+}
+
+// templateExecutor is a parsed text/template or html/template
+type templateExecutor interface {
+	Execute(w io.Writer, data any) error
+}
+
+// ReportTemplate is a report format defined by a template file of the operator. The template renders
+// the Report, its `Burrows` are the burrows it is about.
+// Files ending with `.html` or `.htm`, optionally followed by `.tmpl`, are html/template templates
+// that escape the values, the others text/template templates.
+// The file is parsed again when it changes. A template that does not parse is logged and the previous one is used.
+type ReportTemplate struct {
+	logger *slog.Logger
+	path   string
+	html   bool
+	ext    string
+
+	mu    sync.Mutex
+	tmpl  templateExecutor
+	stamp fileStamp
+}
+
+// NewReportTemplate parses the template file. Register it to use it as a report format.
+func NewReportTemplate(logger *slog.Logger, path string) (*ReportTemplate, error) {
+	t := &ReportTemplate{logger: logger, path: path, ext: "txt"}
+
+	name := strings.TrimSuffix(filepath.Base(path), ".tmpl")
+	if ext := strings.TrimPrefix(filepath.Ext(name), "."); ext != "" && ext != "tmpl" {
+		t.ext = ext
+	}
+	t.html = t.ext == "html" || t.ext == "htm"
+
+	if err := t.reload(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *ReportTemplate) Encode(w io.Writer, r Report) error {
+	t.mu.Lock()
+	if err := t.reload(); err != nil {
+		t.logger.Error("report template not reloaded, the previous one is used", "path", t.path, "error", err.Error())
+	}
+	tmpl := t.tmpl
+	t.mu.Unlock()
+
+	return tmpl.Execute(w, r)
+}
+
+func (t *ReportTemplate) ContentType() string {
+	if t.html {
+		return "text/html; charset=utf-8"
+	}
+	return "text/plain; charset=utf-8"
+}
+
+func (t *ReportTemplate) Extension() string { return t.ext }
+
+// reload parses the file if it changed since it was parsed last
+func (t *ReportTemplate) reload() error {
+	info, err := os.Stat(t.path)
+	if err != nil {
+		return err
+	}
+	stamp := fileStamp{size: info.Size(), modTime: info.ModTime().UnixNano()}
+	if t.tmpl != nil && stamp == t.stamp {
+		return nil
+	}
+
+	b, err := os.ReadFile(t.path)
+	if err != nil {
+		return err
+	}
+
+	name := filepath.Base(t.path)
+	var tmpl templateExecutor
+	if t.html {
+		tmpl, err = htmltemplate.New(name).Funcs(ReportTemplateFuncs).Parse(string(b))
+	} else {
+		tmpl, err = texttemplate.New(name).Funcs(ReportTemplateFuncs).Parse(string(b))
+	}
+	if err != nil {
+		return err
+	}
+
+	if t.tmpl != nil {
+		t.logger.Info("report template reloaded", "path", t.path)
+	}
+	t.tmpl, t.stamp = tmpl, stamp
+	return nil
+}
@@ -0,0 +1,118 @@
+package burrows
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReportTemplate(t *testing.T) {
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	r := testReport
+	r.Burrows = testReportBurrows
+
+	tmpl, err := NewReportTemplate(logger, "../../data/report.md.tmpl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmpl.Extension() != "md" || tmpl.ContentType() != "text/plain; charset=utf-8" {
+		t.Errorf("wrong format of the template. got: %s, %s", tmpl.Extension(), tmpl.ContentType())
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Encode(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"# Burrows on Friday, 01 Mar 2024 10:00",
+		"4 of 6 burrows are available, 36.4% of the slots are taken. 1 burrows collapse within 24 hours.",
+		"| C | c, d | 3.00 | 25d | 0 |",
+		"| D | e | 5.00 | 24d23h | 3 |",
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("the report should contain %q. got:\n%s", expected, buf.String())
+		}
+	}
+}
+
+func TestReportTemplateHTML(t *testing.T) {
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	path := filepath.Join(t.TempDir(), "report.html.tmpl")
+	if err := os.WriteFile(path, []byte(`<h1>{{.VolumeMaxName}}</h1>`), 0o664); err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl, err := NewReportTemplate(logger, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmpl.Extension() != "html" || !strings.HasPrefix(tmpl.ContentType(), "text/html") {
+		t.Errorf("wrong format of the template. got: %s, %s", tmpl.Extension(), tmpl.ContentType())
+	}
+
+	r := testReport
+	r.VolumeMaxName = "<b>Big</b>"
+	var buf bytes.Buffer
+	if err := tmpl.Encode(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "<h1>&lt;b&gt;Big&lt;/b&gt;</h1>" {
+		t.Errorf("the values should be escaped. got: %s", buf.String())
+	}
+}
+
+func TestReportTemplateReload(t *testing.T) {
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	path := filepath.Join(t.TempDir(), "report.tmpl")
+	write := func(content string, mod time.Time) {
+		if err := os.WriteFile(path, []byte(content), 0o664); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mod, mod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	encode := func(tmpl *ReportTemplate) string {
+		var buf bytes.Buffer
+		if err := tmpl.Encode(&buf, testReport); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+
+	start := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	write("free: {{.FreeSlots}}", start)
+
+	if _, err := NewReportTemplate(logger, filepath.Join(t.TempDir(), "missing.tmpl")); err == nil {
+		t.Errorf("a missing template should be refused")
+	}
+	tmpl, err := NewReportTemplate(logger, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tmpl.Extension() != "txt" {
+		t.Errorf("templates without extension should write text files. got: %s", tmpl.Extension())
+	}
+	if s := encode(tmpl); s != "free: 7" {
+		t.Errorf("wrong report. got: %s", s)
+	}
+
+	write("slots: {{.FreeSlots}}", start.Add(time.Minute))
+	if s := encode(tmpl); s != "slots: 7" {
+		t.Errorf("the changed template should be used. got: %s", s)
+	}
+
+	write("broken: {{.FreeSlots", start.Add(2*time.Minute))
+	if s := encode(tmpl); s != "slots: 7" {
+		t.Errorf("a broken template should keep the previous one. got: %s", s)
+	}
+}
@@ -0,0 +1,195 @@
+package burrows
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testReportBurrows have volumes close to their depths
+var testReportBurrows = func() []Burrow {
+	w := 2 / math.Sqrt(math.Pi)
+	return []Burrow{
+		{Name: "A", Capacity: 1, Occupants: []string{"a"}, Depth: 0.3, Width: w, AgeInMin: 100},
+		{Name: "B", Capacity: 2, Depth: 1.5, Width: w, AgeInMin: 200},
+		{Name: "C", Capacity: 2, Occupants: []string{"c", "d"}, Depth: 3, Width: w, AgeInMin: maxAgeInMin},
+		{Name: "D", Capacity: 4, Occupants: []string{"e"}, Depth: 5, Width: w, AgeInMin: maxAgeInMin - 60},
+		{Name: "E", Capacity: 1, Depth: 10, Width: w},
+		{Name: `The "Big" One`, Capacity: 1, Depth: 12, Width: w, AgeInMin: 300},
+	}
+}()
+
+var testReport = func() Report {
+	r := NewReport(testReportBurrows)
+	r.Taken = time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	return r
+}()
+
+func TestNewReport(t *testing.T) {
+
+	r := NewReport(testReportBurrows)
+
+	if r.Count != 6 || r.Slots != 11 || r.Occupants != 4 || r.OccupancyRate != 4.0/11 {
+		t.Errorf("wrong occupancy. got: %d burrows, %d slots, %d occupants, rate %f", r.Count, r.Slots, r.Occupants, r.OccupancyRate)
+	}
+	if r.Collapsed != 1 || r.CollapsingSoon != 1 {
+		t.Errorf("wrong collapses. expected 1 collapsed and 1 collapsing, got: %d and %d", r.Collapsed, r.CollapsingSoon)
+	}
+
+	age := Distribution{Avg: 12090, P50: 200, P90: float64(maxAgeInMin), P99: float64(maxAgeInMin)}
+	if r.Age != age {
+		t.Errorf("wrong age distribution. expected: %+v, got: %+v", age, r.Age)
+	}
+	if r.Depth.P50 != 3 || r.Depth.P90 != 12 {
+		t.Errorf("wrong depth distribution. got: %+v", r.Depth)
+	}
+
+	if counts := []int{1, 0, 1, 1, 1, 2}; !slices.Equal(counts, r.VolumeHistogram.Counts) {
+		t.Errorf("wrong volume histogram. expected: %v, got: %v", counts, r.VolumeHistogram.Counts)
+	}
+
+	names := func(ranked []RankedBurrow) []string {
+		var s []string
+		for _, b := range ranked {
+			s = append(s, b.Name)
+		}
+		return s
+	}
+	if largest := []string{`The "Big" One`, "E", "D", "C", "B"}; !slices.Equal(largest, names(r.Largest)) {
+		t.Errorf("wrong largest burrows. expected: %v, got: %v", largest, names(r.Largest))
+	}
+	if smallest := []string{"A", "B", "C", "D", "E"}; !slices.Equal(smallest, names(r.Smallest)) {
+		t.Errorf("wrong smallest burrows. expected: %v, got: %v", smallest, names(r.Smallest))
+	}
+
+	if empty := NewReport(nil); empty.Count != 0 || empty.Age != (Distribution{}) || len(empty.VolumeHistogram.Counts) != len(VolumeBuckets)+1 {
+		t.Errorf("wrong empty report. got: %+v", empty)
+	}
+}
+
+func TestReportEncoders(t *testing.T) {
+
+	for _, name := range ReportFormats() {
+		t.Run(name, func(t *testing.T) {
+			e, err := LookupReportEncoder(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if err := e.Encode(&buf, testReport); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(buf.String(), "Big") {
+				t.Errorf("the report should name the largest burrow. got: %s", buf.String())
+			}
+		})
+	}
+
+	if _, err := LookupReportEncoder("pdf"); err == nil {
+		t.Errorf("unknown formats should be refused")
+	}
+}
+
+func TestReportEncoderPrometheus(t *testing.T) {
+
+	e, _ := LookupReportEncoder("prometheus")
+	var buf bytes.Buffer
+	if err := e.Encode(&buf, testReport); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"# HELP burrows_free_slots Number of gophers that can still move in.\n# TYPE burrows_free_slots gauge\nburrows_free_slots 7\n",
+		"burrows_collapsed 1\n",
+		"burrows_age_p50 200\n",
+		"# TYPE burrows_volume histogram\nburrows_volume_bucket{le=\"0.5\"} 1\nburrows_volume_bucket{le=\"1\"} 1\n",
+		"burrows_volume_bucket{le=\"+Inf\"} 6\n",
+		"burrows_volume_count 6\n",
+		"# TYPE burrows_largest_volume gauge\nburrows_largest_volume{rank=\"1\",name=\"The \\\"Big\\\" One\"} ",
+	}
+	for _, e := range expected {
+		if !strings.Contains(buf.String(), e) {
+			t.Errorf("the exposition should contain:\n%s\ngot:\n%s", e, buf.String())
+		}
+	}
+}
+
+func TestReportEncoderMachineReadable(t *testing.T) {
+
+	var buf bytes.Buffer
+	e, _ := LookupReportEncoder("json")
+	if err := e.Encode(&buf, testReport); err != nil {
+		t.Fatal(err)
+	}
+	var decoded Report
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(testReport, decoded) {
+		t.Errorf("the JSON report should decode to the same report. expected: %+v, got: %+v", testReport, decoded)
+	}
+
+	buf.Reset()
+	e, _ = LookupReportEncoder("csv")
+	if err := e.Encode(&buf, testReport); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || len(rows[0]) != len(rows[1]) {
+		t.Fatalf("the CSV report should have a header and a row of the same length. got: %v", rows)
+	}
+	columns := make(map[string]string)
+	for i, h := range rows[0] {
+		columns[h] = rows[1][i]
+	}
+	expected := map[string]string{
+		"taken":                      "2024-03-01T10:00:00Z",
+		"free_slots":                 "7",
+		"collapsing_24h":             "1",
+		"volume_bucket_le_+Inf":      "6",
+		"largest_volume_rank_1_name": `The "Big" One`,
+	}
+	for column, value := range expected {
+		if columns[column] != value {
+			t.Errorf("wrong value of the %s column. expected: %s, got: %s", column, value, columns[column])
+		}
+	}
+}
+
+type upperEncoder struct{}
+
+func (upperEncoder) Encode(w io.Writer, r Report) error {
+	_, err := io.WriteString(w, strings.ToUpper(r.VolumeMaxName))
+	return err
+}
+func (upperEncoder) ContentType() string { return "text/plain" }
+func (upperEncoder) Extension() string   { return "up" }
+
+func TestRegisterReportEncoder(t *testing.T) {
+
+	RegisterReportEncoder("Upper", upperEncoder{})
+	t.Cleanup(func() {
+		reportEncodersMu.Lock()
+		delete(reportEncoders, "upper")
+		reportEncodersMu.Unlock()
+	})
+
+	e, err := LookupReportEncoder("upper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := e.Encode(&buf, testReport); err != nil || buf.String() != `THE "BIG" ONE` {
+		t.Errorf("the registered encoder should be used. got: %q, error: %v", buf.String(), err)
+	}
+}
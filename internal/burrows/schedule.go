@@ -0,0 +1,208 @@
+package burrows
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ReportSchedule is a named schedule of periodic reports.
+type ReportSchedule struct {
+	Name string `json:"name" yaml:"name"`
+	Cron Cron   `json:"cron" yaml:"cron"`
+	// Formats of the reports, one file per format. Text by default
+	Formats []string `json:"formats" yaml:"formats"`
+	// Dir is where the report files are written, if the schedule has no sinks
+	Dir string `json:"dir" yaml:"dir"`
+	// Sinks deliver the reports. File sinks without a directory write to `Dir`
+	Sinks []SinkConfig `json:"sinks" yaml:"sinks"`
+	// Selector restricts the reports to the burrows with matching labels
+	Selector Selector `json:"selector" yaml:"selector"`
+	// History also appends the reports to the report history
+	History bool `json:"history" yaml:"history"`
+}
+
+// ReadReportSchedules decodes the schedules of a YAML or JSON document, ex:
+//
+//	schedules:
+//	  - name: hourly
+//	    cron: "0 * * * *"
+//	    formats: [json, prometheus]
+//	    dir: /var/lib/burrows/reports/hourly
+//	  - name: daily
+//	    cron: "CRON_TZ=UTC 0 0 * * *"
+//	    formats: [markdown]
+//	    sinks:
+//	      - type: email
+//	        smtp: mail.example.com:25
+//	        from: burrows@example.com
+//	        to: [oncall@example.com]
+//	      - type: webhook
+//	        url: https://chat.example.com/hooks/burrows
+//
+// Names have to be unique and fit into file names, the formats registered and the sinks valid.
+func ReadReportSchedules(r io.Reader) ([]ReportSchedule, error) {
+	var doc struct {
+		Schedules []ReportSchedule `yaml:"schedules"`
+	}
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(&doc); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	names := make(map[string]bool)
+	for i, s := range doc.Schedules {
+		if s.Name == "" {
+			return nil, fmt.Errorf("schedule %d has no name", i+1)
+		}
+		if strings.ContainsAny(s.Name, `/\`) {
+			return nil, fmt.Errorf("invalid schedule name, it is part of the names of the report files: %s", s.Name)
+		}
+		if names[s.Name] {
+			return nil, fmt.Errorf("duplicate schedule: %s", s.Name)
+		}
+		names[s.Name] = true
+
+		if s.Cron.String() == "" {
+			return nil, fmt.Errorf("schedule %s has no cron expression", s.Name)
+		}
+		if len(s.Formats) == 0 {
+			doc.Schedules[i].Formats = []string{"text"}
+		}
+		for _, f := range s.Formats {
+			if _, err := LookupReportEncoder(f); err != nil {
+				return nil, fmt.Errorf("schedule %s: %w", s.Name, err)
+			}
+		}
+		for _, sink := range s.Sinks {
+			if err := sink.Validate(); err != nil {
+				return nil, fmt.Errorf("schedule %s: %w", s.Name, err)
+			}
+		}
+	}
+
+	return doc.Schedules, nil
+}
+
+// reportRunTimeout limits the time to write the report of a schedule and to deliver it to all the sinks
+const reportRunTimeout = 5 * time.Minute
+
+// ScheduleStatus tells when a schedule ran and runs next.
+type ScheduleStatus struct {
+	ReportSchedule
+	// LastRun is nil until the schedule ran, NextRun if it never runs anymore
+	LastRun   *time.Time `json:"lastRun,omitempty"`
+	NextRun   *time.Time `json:"nextRun,omitempty"`
+	LastError string     `json:"lastError,omitempty"`
+	// Running is true while the report of the last run is written
+	Running bool `json:"running,omitempty"`
+}
+
+// Scheduler runs the report schedules when they are due.
+// Every run has its own go routine, so that a slow sink does not delay the other schedules.
+// A schedule never runs twice at once: while it is still running, it is not due.
+// A schedule that fails is logged and runs again at its next time.
+type Scheduler struct {
+	lg  *slog.Logger
+	run func(ctx context.Context, s ReportSchedule, at time.Time) error
+	// runs are the reports that are being written
+	runs sync.WaitGroup
+
+	mu       sync.Mutex
+	statuses []ScheduleStatus
+}
+
+// NewScheduler plans the next run of every schedule. `run` writes the report of a schedule,
+// `at` is the time the schedule was due. Its context is cancelled after `reportRunTimeout`.
+func NewScheduler(logger *slog.Logger, clock Clock, run func(ctx context.Context, s ReportSchedule, at time.Time) error, schedules ...ReportSchedule) *Scheduler {
+	now := clock.Now()
+	s := &Scheduler{lg: logger, run: run}
+	for _, sched := range schedules {
+		st := ScheduleStatus{ReportSchedule: sched}
+		if next := sched.Cron.Next(now); !next.IsZero() {
+			st.NextRun = &next
+		}
+		s.statuses = append(s.statuses, st)
+	}
+	return s
+}
+
+// Run checks at every tick of the ticker which schedules are due, until the context is done.
+// A schedule that was due several times since it ran last only runs once.
+// It returns once the reports that are being written are done.
+func (s *Scheduler) Run(ctx context.Context, ticker Ticker) {
+	defer ticker.Stop()
+	defer s.runs.Wait()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C():
+			s.runDue(ctx, now)
+		}
+	}
+}
+
+// runDue starts the schedules that are due and plans their next run
+func (s *Scheduler) runDue(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// schedules are never added after the start, only their statuses change
+	for i := range s.statuses {
+		st := &s.statuses[i]
+		if st.Running || st.NextRun == nil || now.Before(*st.NextRun) {
+			continue
+		}
+
+		ran, due := now, *st.NextRun
+		st.LastRun, st.NextRun, st.Running = &ran, nil, true
+		// the next run follows the one that was due, so that intervals don't drift by the delay of the ticks
+		next := st.Cron.Next(due)
+		if !next.IsZero() && !next.After(now) {
+			next = st.Cron.Next(now)
+		}
+		if !next.IsZero() {
+			st.NextRun = &next
+		}
+
+		s.runs.Add(1)
+		go s.runOnce(ctx, i, st.ReportSchedule, due)
+	}
+}
+
+// runOnce writes the report of the schedule at position i, the statuses can be listed meanwhile
+func (s *Scheduler) runOnce(ctx context.Context, i int, sched ReportSchedule, due time.Time) {
+	defer s.runs.Done()
+
+	ctx, cancel := context.WithTimeout(ctx, reportRunTimeout)
+	defer cancel()
+	err := s.run(ctx, sched, due)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := &s.statuses[i]
+	st.Running, st.LastError = false, ""
+	if err != nil {
+		st.LastError = err.Error()
+		s.lg.Error("scheduled report failed", "schedule", st.Name, "error", err.Error())
+	}
+}
+
+// Schedules returns the status of every schedule, in the order in which they were added.
+func (s *Scheduler) Schedules() []ScheduleStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	statuses := make([]ScheduleStatus, len(s.statuses))
+	copy(statuses, s.statuses)
+	return statuses
+}
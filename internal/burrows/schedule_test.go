@@ -0,0 +1,265 @@
+package burrows
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReadReportSchedules(t *testing.T) {
+
+	doc := `
+schedules:
+  - name: hourly
+    cron: "0 * * * *"
+    formats: [json, prometheus]
+    dir: /var/lib/burrows/reports/hourly
+    selector: site=north
+    history: true
+  - name: daily
+    cron: "CRON_TZ=UTC 0 0 * * *"
+`
+	schedules, err := ReadReportSchedules(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schedules) != 2 {
+		t.Fatalf("expected 2 schedules, got: %+v", schedules)
+	}
+	hourly, daily := schedules[0], schedules[1]
+	if hourly.Name != "hourly" || hourly.Cron.String() != "0 * * * *" || hourly.Dir != "/var/lib/burrows/reports/hourly" ||
+		!hourly.History || hourly.Selector.String() != "site=north" || strings.Join(hourly.Formats, ",") != "json,prometheus" {
+		t.Errorf("wrong hourly schedule. got: %+v", hourly)
+	}
+	if next := daily.Cron.Next(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)); !next.Equal(time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("the daily schedule should run at midnight UTC. got: %v", next)
+	}
+	if len(daily.Formats) != 1 || daily.Formats[0] != "text" {
+		t.Errorf("the reports should be text by default. got: %v", daily.Formats)
+	}
+
+	for _, invalid := range []string{
+		"schedules:\n  - cron: '@daily'",
+		"schedules:\n  - name: a\n    cron: '@daily'\n  - name: a\n    cron: '@hourly'",
+		"schedules:\n  - name: a",
+		"schedules:\n  - name: a\n    cron: '61 * * * *'",
+		"schedules:\n  - name: a\n    cron: '@daily'\n    formats: [pdf]",
+		"schedules:\n  - name: a\n    cron: '@daily'\n    selector: 'site in north'",
+		"schedules:\n  - name: a\n    cron: '@daily'\n    every: 1h",
+		"schedules:\n  - name: a/b\n    cron: '@daily'",
+	} {
+		if _, err := ReadReportSchedules(strings.NewReader(invalid)); err == nil {
+			t.Errorf("the schedules should be refused:\n%s", invalid)
+		}
+	}
+}
+
+// advanceScheduler moves the clock forward a minute at a time, and waits for the runs of every minute to be done
+func advanceScheduler(clock *FakeClock, sched *Scheduler, d time.Duration) {
+	for range d / time.Minute {
+		clock.Advance(time.Minute)
+		now := clock.Now()
+		for slices.ContainsFunc(sched.Schedules(), func(st ScheduleStatus) bool {
+			return st.Running || (st.NextRun != nil && !st.NextRun.After(now))
+		}) {
+			time.Sleep(time.Millisecond)
+		}
+	}
+}
+
+func TestScheduler(t *testing.T) {
+
+	start := time.Date(2024, 3, 1, 10, 59, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	hourly, _ := ParseCron("CRON_TZ=UTC @hourly")
+	often, _ := ParseCron("@every 20m")
+	never, _ := ParseCron("CRON_TZ=UTC 0 0 30 2 *")
+
+	var mu sync.Mutex
+	ran := make(map[string][]time.Time)
+	run := func(_ context.Context, s ReportSchedule, at time.Time) error {
+		mu.Lock()
+		defer mu.Unlock()
+		ran[s.Name] = append(ran[s.Name], at)
+		if s.Name == "often" {
+			return errors.New("disk full")
+		}
+		return nil
+	}
+	sched := NewScheduler(logger, clock, run,
+		ReportSchedule{Name: "hourly", Cron: hourly},
+		ReportSchedule{Name: "often", Cron: often},
+		ReportSchedule{Name: "never", Cron: never},
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	ticker := clock.NewTicker(time.Minute)
+	go func() {
+		sched.Run(ctx, ticker)
+		close(done)
+	}()
+	advanceScheduler(clock, sched, 2*time.Hour)
+	cancel()
+	<-done
+
+	if len(ran["hourly"]) != 2 || !ran["hourly"][0].Equal(time.Date(2024, 3, 1, 11, 0, 0, 0, time.UTC)) {
+		t.Errorf("the hourly schedule should run on the hour. got: %v", ran["hourly"])
+	}
+	if len(ran["often"]) != 6 || len(ran["never"]) != 0 {
+		t.Errorf("wrong runs. got: %v", ran)
+	}
+
+	statuses := sched.Schedules()
+	if len(statuses) != 3 {
+		t.Fatalf("expected 3 schedules, got: %+v", statuses)
+	}
+	if st := statuses[0]; st.LastRun == nil || !st.LastRun.Equal(start.Add(61*time.Minute)) || st.NextRun == nil ||
+		!st.NextRun.Equal(time.Date(2024, 3, 1, 13, 0, 0, 0, time.UTC)) || st.LastError != "" {
+		t.Errorf("wrong status of the hourly schedule. got: %+v", st)
+	}
+	if st := statuses[1]; st.LastError != "disk full" || st.NextRun == nil {
+		t.Errorf("a failed schedule should run again. got: %+v", st)
+	}
+	if st := statuses[2]; st.LastRun != nil || st.NextRun != nil {
+		t.Errorf("a schedule that never runs should have no run times. got: %+v", st)
+	}
+}
+
+func TestSchedulerSlowRun(t *testing.T) {
+
+	clock := NewFakeClock(time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC))
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	every, _ := ParseCron("@every 1m")
+
+	slowStarted, release := make(chan time.Time, 10), make(chan struct{})
+	fastRan := make(chan time.Time, 10)
+	run := func(ctx context.Context, s ReportSchedule, at time.Time) error {
+		if s.Name == "fast" {
+			fastRan <- at
+			return nil
+		}
+		if _, ok := ctx.Deadline(); !ok {
+			t.Error("a run should have a timeout")
+		}
+		slowStarted <- at
+		<-release
+		return nil
+	}
+	sched := NewScheduler(logger, clock, run, ReportSchedule{Name: "slow", Cron: every}, ReportSchedule{Name: "fast", Cron: every})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	ticker := clock.NewTicker(time.Minute)
+	go func() {
+		sched.Run(ctx, ticker)
+		close(done)
+	}()
+
+	wait := func(ch <-chan time.Time) time.Time {
+		t.Helper()
+		select {
+		case at := <-ch:
+			return at
+		case <-time.After(5 * time.Second):
+			t.Fatal("the schedule did not run")
+			return time.Time{}
+		}
+	}
+
+	// the slow schedule does not delay the fast one, and does not run again before it is done
+	for i := 1; i <= 3; i++ {
+		clock.Advance(time.Minute)
+		if at := wait(fastRan); !at.Equal(clock.Now()) {
+			t.Errorf("the fast schedule should run on time. expected: %v, got: %v", clock.Now(), at)
+		}
+	}
+	first := wait(slowStarted)
+	if len(slowStarted) != 0 || !first.Equal(clock.Now().Add(-2*time.Minute)) {
+		t.Errorf("the slow schedule should only run once at a time. started at %v, then %d more times", first, len(slowStarted))
+	}
+	if st := sched.Schedules()[0]; !st.Running {
+		t.Errorf("the slow schedule should be running. got: %+v", st)
+	}
+
+	// once it is done, it runs for the time it was due while it was running
+	release <- struct{}{}
+	for sched.Schedules()[0].Running {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(time.Minute)
+	wait(fastRan)
+	if at := wait(slowStarted); !at.Equal(first.Add(time.Minute)) {
+		t.Errorf("the slow schedule should run once when it is done. got: %v", at)
+	}
+
+	close(release)
+	cancel()
+	<-done
+	if st := sched.Schedules()[0]; st.Running {
+		t.Errorf("the runs should be done when the scheduler stops. got: %+v", st)
+	}
+}
+
+func TestSchedulesShareDirectory(t *testing.T) {
+
+	start := time.Date(2024, 3, 1, 23, 59, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	dir := t.TempDir()
+
+	hourly, _ := ParseCron("CRON_TZ=UTC @hourly")
+	daily, _ := ParseCron("CRON_TZ=UTC @daily")
+	sinks := make(map[string]ReportSink)
+	for _, name := range []string{"hourly", "daily"} {
+		sink, err := NewReportSink(logger, SinkConfig{Type: SinkFile}, ReportFiles{Dir: dir, Schedule: name, Keep: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sinks[name] = sink
+	}
+	run := func(ctx context.Context, s ReportSchedule, at time.Time) error {
+		r := testReport
+		r.Taken = at
+		return sinks[s.Name].Send(ctx, r, testEncoders(t, "json"))
+	}
+	sched := NewScheduler(logger, clock, run, ReportSchedule{Name: "hourly", Cron: hourly}, ReportSchedule{Name: "daily", Cron: daily})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	ticker := clock.NewTicker(time.Minute)
+	go func() {
+		sched.Run(ctx, ticker)
+		close(done)
+	}()
+	// both schedules are due at midnight, the hourly one once more an hour later
+	advanceScheduler(clock, sched, 61*time.Minute)
+	cancel()
+	<-done
+
+	for _, st := range sched.Schedules() {
+		if st.LastError != "" {
+			t.Errorf("schedule %s failed: %s", st.Name, st.LastError)
+		}
+	}
+	for name, expected := range map[string]time.Time{
+		"hourly": time.Date(2024, 3, 2, 1, 0, 0, 0, time.UTC),
+		"daily":  time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC),
+	} {
+		files, err := ReportFiles{Dir: dir, Schedule: name}.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(files) != 1 || !files[0].Taken.Equal(expected) {
+			t.Errorf("the %s schedule should keep its own newest report. got: %+v", name, files)
+		}
+	}
+}
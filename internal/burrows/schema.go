@@ -0,0 +1,113 @@
+package burrows
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// SchemaVersion is the version of the snapshots written by this build.
+//
+//	1: a plain list of burrows that are either occupied or not
+//	2: a list of burrows with a capacity and occupants, optionally with the time it was taken
+//	3: an envelope with the version and metadata
+const SchemaVersion = 3
+
+// ErrNewerSchema is returned for snapshots written by a newer build.
+var ErrNewerSchema = errors.New("snapshot written by a newer version")
+
+// document is a snapshot of any schema version, decoded only as far as the migrations need it
+type document map[string]json.RawMessage
+
+// migrations upgrade a document from the version of their key to the next one.
+// Every change of the schema bumps `SchemaVersion` and registers a migration from the previous version.
+var migrations = map[int]func(doc document) error{
+	1: migrateOccupiedToOccupants,
+	2: migrateToEnvelope,
+}
+
+// decodeDocument detects the schema version of a snapshot.
+// Versions 1 and 2 have no version field, a plain list of burrows is normalized to an object.
+func decodeDocument(b []byte) (document, int, error) {
+	b = bytes.TrimSpace(b)
+	if len(b) > 0 && b[0] == '[' {
+		return document{"burrows": b}, 1, nil
+	}
+
+	var doc document
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, 0, err
+	}
+	raw, ok := doc["version"]
+	if !ok {
+		return doc, 2, nil
+	}
+	var version int
+	if err := json.Unmarshal(raw, &version); err != nil {
+		return nil, 0, fmt.Errorf("invalid snapshot version: %w", err)
+	}
+	if version > SchemaVersion {
+		return nil, 0, fmt.Errorf("%w: %d, this build supports up to %d", ErrNewerSchema, version, SchemaVersion)
+	}
+	if version < 1 {
+		return nil, 0, fmt.Errorf("invalid snapshot version: %d", version)
+	}
+
+	return doc, version, nil
+}
+
+// migrate upgrades the document from the version to the current one.
+func migrate(doc document, version int) error {
+	for v := version; v < SchemaVersion; v++ {
+		if err := migrations[v](doc); err != nil {
+			return fmt.Errorf("migrate snapshot from version %d: %w", v, err)
+		}
+	}
+	return nil
+}
+
+// legacyGopher is the occupant of the burrows that were occupied in version 1, which did not know the gophers by name
+const legacyGopher = "legacy-gopher"
+
+// migrateOccupiedToOccupants replaces the `occupied` flag of every burrow with a capacity and occupants.
+// An occupied burrow hosted a single, anonymous gopher. Lists of burrows that already know their
+// occupants were written by early builds of version 2 and are left untouched.
+func migrateOccupiedToOccupants(doc document) error {
+	var burrows []map[string]json.RawMessage
+	if err := json.Unmarshal(doc["burrows"], &burrows); err != nil {
+		return err
+	}
+
+	for _, b := range burrows {
+		raw, ok := b["occupied"]
+		if !ok {
+			continue
+		}
+		delete(b, "occupied")
+
+		var occupied bool
+		if err := json.Unmarshal(raw, &occupied); err != nil {
+			return err
+		}
+		if _, ok := b["capacity"]; !ok {
+			b["capacity"] = json.RawMessage(`1`)
+		}
+		if occupied {
+			b["occupants"] = json.RawMessage(`["` + legacyGopher + `"]`)
+		}
+	}
+
+	raw, err := json.Marshal(burrows)
+	if err != nil {
+		return err
+	}
+	doc["burrows"] = raw
+	return nil
+}
+
+// migrateToEnvelope only adds the version, the burrows and the checksum are the same in version 3.
+func migrateToEnvelope(doc document) error {
+	doc["version"] = json.RawMessage(`3`)
+	return nil
+}
@@ -0,0 +1,98 @@
+package burrows
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadSnapshotMigrations(t *testing.T) {
+
+	scenarios := []struct {
+		name     string
+		data     string
+		from     int
+		expected []Burrow
+	}{
+		{
+			name: "version 1, occupied flag",
+			data: `[{"name": "a", "occupied": true, "depth": 1, "age": 10}, {"name": "b", "occupied": false}]`,
+			from: 1,
+			expected: []Burrow{
+				{Name: "a", Capacity: 1, Occupants: []string{legacyGopher}, Depth: 1, AgeInMin: 10},
+				{Name: "b", Capacity: 1},
+			},
+		},
+		{
+			name:     "version 1, occupants already known",
+			data:     `[{"name": "a", "capacity": 3, "occupants": ["Gus"]}]`,
+			from:     1,
+			expected: []Burrow{{Name: "a", Capacity: 3, Occupants: []string{"Gus"}}},
+		},
+		{
+			name:     "version 2",
+			data:     `{"taken": "2024-03-01T10:00:00Z", "burrows": [{"name": "a", "capacity": 2}], "checksum": "CHECKSUM"}`,
+			from:     2,
+			expected: []Burrow{{Name: "a", Capacity: 2}},
+		},
+		{
+			name:     "version 3",
+			data:     `{"version": 3, "metadata": {"generator": "hand written"}, "burrows": [{"name": "a", "capacity": 2}]}`,
+			expected: []Burrow{{Name: "a", Capacity: 2}},
+		},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			t.Parallel()
+
+			// the checksum of the burrows is the same in version 2 and 3
+			sum, err := Snapshot{Burrows: s.expected}.checksum()
+			if err != nil {
+				t.Fatal(err)
+			}
+			data := strings.Replace(s.data, "CHECKSUM", sum, 1)
+
+			snap, err := ReadSnapshot(strings.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if snap.Version != SchemaVersion || snap.Metadata.MigratedFrom != s.from {
+				t.Errorf("expected version %d migrated from %d, got: %+v", SchemaVersion, s.from, snap)
+			}
+			if !reflect.DeepEqual(s.expected, snap.Burrows) {
+				t.Errorf("wrong burrows. expected: %+v, got: %+v", s.expected, snap.Burrows)
+			}
+		})
+	}
+}
+
+func TestReadSnapshotVersions(t *testing.T) {
+
+	var buf strings.Builder
+	taken := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	if err := WriteSnapshot(&buf, Snapshot{Taken: taken, Burrows: []Burrow{{Name: "a"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"version":3`) {
+		t.Errorf("the snapshot should be written in the current version. got: %s", buf.String())
+	}
+
+	snap, err := ReadSnapshot(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snap.Metadata.Generator != Generator || snap.Metadata.MigratedFrom != 0 || !snap.Taken.Equal(taken) {
+		t.Errorf("wrong snapshot. got: %+v", snap)
+	}
+
+	if _, err := ReadSnapshot(strings.NewReader(`{"version": 4, "burrows": []}`)); !errors.Is(err, ErrNewerSchema) {
+		t.Errorf("expected: %v, got: %v", ErrNewerSchema, err)
+	}
+	if _, err := ReadSnapshot(strings.NewReader(`{"version": 0, "burrows": []}`)); err == nil {
+		t.Error("version 0 should be invalid")
+	}
+}
@@ -0,0 +1,220 @@
+package burrows
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+type operator string
+
+const (
+	opEquals    operator = "="
+	opNotEquals operator = "!="
+	opIn        operator = "in"
+	opNotIn     operator = "notin"
+	opExists    operator = "exists"
+	opNotExists operator = "!"
+)
+
+// requirement is a single condition of a selector, ex: `site=north` or `tier in (gold,premium)`
+type requirement struct {
+	key    string
+	op     operator
+	values []string
+}
+
+// Selector selects burrows by their labels.
+// It is a comma separated list of requirements which all have to match:
+//
+//	site=north         the label has the value
+//	site!=north        the label is missing or has another value
+//	tier in (a,b)      the label has one of the values
+//	tier notin (a,b)   the label is missing or has none of the values
+//	soil               the label exists
+//	!soil              the label does not exist
+//
+// The zero value selects all the burrows.
+type Selector struct {
+	reqs []requirement
+}
+
+var (
+	labelToken = `[A-Za-z0-9][A-Za-z0-9._/-]*`
+	labelRe    = regexp.MustCompile(`^` + labelToken + `$`)
+	setRe      = regexp.MustCompile(`^(` + labelToken + `)\s+(in|notin)\s*\(([^()]*)\)$`)
+)
+
+// ParseSelector parses the text representation of a selector.
+// An empty string returns a selector that matches everything.
+func ParseSelector(s string) (Selector, error) {
+	var sel Selector
+	for _, term := range splitTerms(s) {
+		req, err := parseRequirement(term)
+		if err != nil {
+			return Selector{}, err
+		}
+		sel.reqs = append(sel.reqs, req)
+	}
+	return sel, nil
+}
+
+// splitTerms splits the selector on commas that are not part of a set of values
+func splitTerms(s string) []string {
+	var terms []string
+	depth, start := 0, 0
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				terms = append(terms, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	terms = append(terms, s[start:])
+
+	return slices.DeleteFunc(terms, func(t string) bool { return strings.TrimSpace(t) == "" })
+}
+
+func parseRequirement(term string) (requirement, error) {
+	term = strings.TrimSpace(term)
+
+	if m := setRe.FindStringSubmatch(term); m != nil {
+		req := requirement{key: m[1], op: operator(m[2])}
+		for _, v := range strings.Split(m[3], ",") {
+			v = strings.TrimSpace(v)
+			if !labelRe.MatchString(v) {
+				return requirement{}, fmt.Errorf("invalid value %q in selector %q", v, term)
+			}
+			req.values = append(req.values, v)
+		}
+		return req, nil
+	}
+
+	req := requirement{op: opExists}
+	key, value := term, ""
+	for _, op := range []operator{opNotEquals, "==", opEquals} {
+		if k, v, found := strings.Cut(term, string(op)); found {
+			key, value = strings.TrimSpace(k), strings.TrimSpace(v)
+			req.op = op
+			if op == "==" {
+				req.op = opEquals
+			}
+			break
+		}
+	}
+	if req.op == opExists && strings.HasPrefix(key, "!") {
+		key = strings.TrimSpace(key[1:])
+		req.op = opNotExists
+	}
+
+	if !labelRe.MatchString(key) {
+		return requirement{}, fmt.Errorf("invalid key %q in selector %q", key, term)
+	}
+	req.key = key
+
+	if req.op == opEquals || req.op == opNotEquals {
+		if !labelRe.MatchString(value) {
+			return requirement{}, fmt.Errorf("invalid value %q in selector %q", value, term)
+		}
+		req.values = []string{value}
+	}
+
+	return req, nil
+}
+
+// Matches returns `true` if the labels satisfy all the requirements of the selector.
+func (s Selector) Matches(labels map[string]string) bool {
+	for _, r := range s.reqs {
+		v, ok := labels[r.key]
+		switch r.op {
+		case opExists:
+			if !ok {
+				return false
+			}
+		case opNotExists:
+			if ok {
+				return false
+			}
+		case opEquals, opIn:
+			if !ok || !slices.Contains(r.values, v) {
+				return false
+			}
+		case opNotEquals, opNotIn:
+			if ok && slices.Contains(r.values, v) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Empty returns `true` if the selector matches every burrow.
+func (s Selector) Empty() bool {
+	return len(s.reqs) == 0
+}
+
+func (s Selector) String() string {
+	terms := make([]string, len(s.reqs))
+	for i, r := range s.reqs {
+		switch r.op {
+		case opExists:
+			terms[i] = r.key
+		case opNotExists:
+			terms[i] = "!" + r.key
+		case opEquals, opNotEquals:
+			terms[i] = r.key + string(r.op) + r.values[0]
+		default:
+			terms[i] = fmt.Sprintf("%s %s (%s)", r.key, r.op, strings.Join(r.values, ","))
+		}
+	}
+	return strings.Join(terms, ",")
+}
+
+// MarshalText allows a selector to be used in JSON documents, ex: a Rental
+func (s Selector) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText parses a selector from JSON documents, ex: a Rental
+func (s *Selector) UnmarshalText(text []byte) error {
+	sel, err := ParseSelector(string(text))
+	if err != nil {
+		return err
+	}
+	*s = sel
+	return nil
+}
+
+// ValidateLabels checks that all keys and values of the labels can be used in a selector.
+func ValidateLabels(labels map[string]string) error {
+	for k, v := range labels {
+		if !labelRe.MatchString(k) {
+			return fmt.Errorf("invalid label key %q", k)
+		}
+		if !labelRe.MatchString(v) {
+			return fmt.Errorf("invalid value %q for label %q", v, k)
+		}
+	}
+	return nil
+}
+
+// Filter returns the burrows matched by the selector.
+func Filter(burrows []Burrow, sel Selector) []Burrow {
+	if sel.Empty() {
+		return burrows
+	}
+	var selected []Burrow
+	for _, b := range burrows {
+		if sel.Matches(b.Labels) {
+			selected = append(selected, b)
+		}
+	}
+	return selected
+}
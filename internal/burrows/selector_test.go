@@ -0,0 +1,78 @@
+package burrows
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSelectorMatches(t *testing.T) {
+
+	labels := map[string]string{"site": "north", "soil": "clay", "tier": "premium"}
+
+	scenarios := []struct {
+		selector string
+		matches  bool
+	}{
+		{selector: "", matches: true},
+		{selector: "site=north", matches: true},
+		{selector: "site==north", matches: true},
+		{selector: "site=south", matches: false},
+		{selector: "site!=south", matches: true},
+		{selector: "site=north,tier=premium", matches: true},
+		{selector: "site=north, tier=basic", matches: false},
+		{selector: "tier in (gold, premium)", matches: true},
+		{selector: "tier notin (gold,premium)", matches: false},
+		{selector: "soil in (sand),site=north", matches: false},
+		{selector: "soil", matches: true},
+		{selector: "!soil", matches: false},
+		{selector: "!lake", matches: true},
+		{selector: "lake!=yes", matches: true},
+		{selector: "lake in (yes)", matches: false},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.selector, func(t *testing.T) {
+			t.Parallel()
+
+			sel, err := ParseSelector(s.selector)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if m := sel.Matches(labels); m != s.matches {
+				t.Errorf("wrong match for %v. expected: %v, got: %v", sel, s.matches, m)
+			}
+		})
+	}
+}
+
+func TestSelectorInvalid(t *testing.T) {
+
+	for _, s := range []string{"site=", "=north", "tier in (gold,)", "tier in gold", "si te=north", "site=no rth", "!"} {
+		t.Run(s, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := ParseSelector(s); err == nil {
+				t.Errorf("expected an error for %q", s)
+			}
+		})
+	}
+}
+
+func TestSelectorJSON(t *testing.T) {
+
+	var r Rental
+	if err := json.Unmarshal([]byte(`{"gophers": ["a"], "selector": "site=north,tier in (gold,premium)"}`), &r); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := json.Marshal(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"gophers":["a"],"selector":"site=north,tier in (gold,premium)"}`
+	if string(b) != expected {
+		t.Errorf("wrong encoding. expected: %s, got: %s", expected, b)
+	}
+}
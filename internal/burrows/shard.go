@@ -0,0 +1,278 @@
+package burrows
+
+import (
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+)
+
+// shardSize is the maximum number of burrows in a shard
+const shardSize = 4096
+
+// shard is a batch of burrows that is managed by a Manager.
+// It has its own lifecycle defined in `start()`.
+// It owns the data of its burrows and does not allow direct access to it.
+// Instead of one go routine and one ticker per burrow, the manager keeps a single ticker
+// and asks every shard to age all of its burrows at once.
+// Every change is appended to the event log and written to the store before it is acknowledged.
+// The aging of the burrows is not saved, the store has the time of the last save of every burrow instead.
+// It is only logged once it adds up to `agedEventMinutes`, when burrows collapse and before the other events.
+type shard struct {
+	lg     *slog.Logger
+	store  Store
+	events EventLog
+	clock  Clock
+	// index is the position of the shard in the manager. It ties the events to the shard
+	index int
+	// unlogged is the aging since the last event. Only the go routine of the shard uses it
+	unlogged *aging
+
+	requests chan Request
+}
+
+// aging is a series of ticks of the same number of minutes
+type aging struct {
+	step, ticks int
+}
+
+// newShard returns the shard at position `index` of the manager. It starts with the initial burrows.
+func newShard(m *manager, index int, initial []Burrow) shard {
+	sh := shard{
+		lg:       m.lg,
+		store:    m.store,
+		events:   m.events,
+		clock:    m.clock,
+		index:    index,
+		unlogged: &aging{},
+		requests: make(chan Request),
+	}
+	go sh.start(initial)
+	return sh
+}
+
+func (sh *shard) start(initial []Burrow) {
+
+	burrows := slices.Clone(initial)
+	sh.added(burrows)
+
+	// find returns the index of the burrow with the given name or -1
+	find := func(name string) int {
+		return slices.IndexFunc(burrows, func(b Burrow) bool { return b.Name == name })
+	}
+
+	for req := range sh.requests {
+		switch req.name {
+		case ReqTick:
+			// an aging event is a series of ticks of the same length, so that the replay ages the burrows the same way
+			if sh.unlogged.ticks > 0 && sh.unlogged.step != req.minutes {
+				sh.record()
+			}
+			collapsed := ageBurrows(burrows, req.minutes)
+			sh.unlogged.step, sh.unlogged.ticks = req.minutes, sh.unlogged.ticks+1
+
+			now := sh.clock.Now()
+			var events []Event
+			for _, b := range collapsed {
+				events = append(events, Event{At: now, Type: EventCollapsed, Shard: sh.index, Burrow: &b})
+			}
+			if len(events) > 0 || sh.unlogged.step*sh.unlogged.ticks >= agedEventMinutes {
+				sh.record(events...)
+			}
+			// the stores catch up with the age of the burrows when they are loaded, only collapses are saved
+			sh.save(now, collapsed...)
+		case ReqCheckpoint:
+			err := sh.checkpoint()
+			req.response <- Response{burrows: slices.Clone(burrows), err: err}
+		case ReqAdd:
+			burrows = append(burrows, req.add...)
+			sh.added(req.add)
+		case ReqSync:
+			var resp Response
+			for _, record := range req.records {
+				i := find(record.Name)
+				if i < 0 {
+					continue
+				}
+				resp.matched = append(resp.matched, record.Name)
+
+				if record.Removed {
+					removed := burrows[i]
+					if err := sh.remove(removed); err != nil {
+						sh.lg.Error("decommissioning not saved", "name", removed.Name, "error", err.Error())
+						continue
+					}
+					burrows = slices.Delete(burrows, i, i+1)
+					sh.lg.Info("burrow decommissioned", "name", removed.Name, "occupants", removed.Occupants)
+					resp.removed = append(resp.removed, removed)
+					continue
+				}
+
+				changed := burrows[i]
+				if !changed.Reconcile(record) {
+					continue
+				}
+				if err := sh.commit(EventUpdated, changed, nil); err != nil {
+					sh.lg.Error("reconciliation not saved", "name", changed.Name, "error", err.Error())
+					continue
+				}
+				burrows[i] = changed
+				sh.lg.Info("burrow reconciled", "name", changed.Name)
+				resp.burrows = append(resp.burrows, changed)
+			}
+			req.response <- resp
+		case ReqClose:
+			sh.lg.Info("close shard", "burrows", len(burrows))
+			err := sh.checkpoint()
+			req.response <- Response{burrows: burrows, err: err}
+			return
+		case ReqStatus:
+			req.response <- Response{burrows: slices.Clone(burrows)}
+		case ReqGopher:
+			i := find(req.burrow)
+			if i < 0 {
+				req.response <- Response{err: ErrUnknownBurrow}
+				continue
+			}
+			changed := burrows[i]
+			if !changed.MoveIn(req.gophers...) {
+				req.response <- Response{burrow: burrows[i], err: ErrNoRoom}
+				continue
+			}
+			if err := sh.commit(EventRented, changed, req.gophers); err != nil {
+				sh.lg.Error("rental not saved", "name", changed.Name, "error", err.Error())
+				req.response <- Response{burrow: burrows[i], err: err}
+				continue
+			}
+			burrows[i] = changed
+			sh.lg.Debug("gophers moved in", "name", changed.Name, "gophers", req.gophers)
+			req.response <- Response{burrow: changed}
+		case ReqVacate:
+			i := find(req.burrow)
+			if i < 0 {
+				req.response <- Response{err: ErrUnknownBurrow}
+				continue
+			}
+			changed := burrows[i]
+			gophers := req.gophers
+			if len(gophers) == 0 {
+				gophers = changed.Occupants
+			}
+			if !changed.MoveOut(gophers...) {
+				req.response <- Response{burrow: burrows[i], err: ErrNotOccupant}
+				continue
+			}
+			if err := sh.commit(EventVacated, changed, gophers); err != nil {
+				sh.lg.Error("vacation not saved", "name", changed.Name, "error", err.Error())
+				req.response <- Response{burrow: burrows[i], err: err}
+				continue
+			}
+			burrows[i] = changed
+			sh.lg.Info("gophers moved out", "name", changed.Name, "gophers", gophers)
+			req.response <- Response{burrow: changed}
+		case ReqLabels:
+			i := find(req.burrow)
+			if i < 0 {
+				req.response <- Response{err: ErrUnknownBurrow}
+				continue
+			}
+			changed := burrows[i]
+			changed.Labels = maps.Clone(req.labels)
+			if err := sh.commit(EventLabeled, changed, nil); err != nil {
+				sh.lg.Error("labels not saved", "name", changed.Name, "error", err.Error())
+				req.response <- Response{burrow: burrows[i], err: err}
+				continue
+			}
+			burrows[i] = changed
+			sh.lg.Info("labels changed", "name", changed.Name, "labels", changed.Labels)
+			req.response <- Response{burrow: changed}
+		}
+	}
+}
+
+// commit persists the change of a single burrow before it is acknowledged.
+// The event log comes first, it is the one that is replayed after a crash.
+// A change that the store refuses is rejected, so it is aborted in the log
+func (sh *shard) commit(typ EventType, changed Burrow, gophers []string) error {
+	e := Event{At: sh.clock.Now(), Type: typ, Shard: sh.index, Burrow: &changed, Gophers: gophers}
+	if err := sh.append(e); err != nil {
+		return err
+	}
+	if err := sh.store.Save(e.At, changed); err != nil {
+		sh.abort(e)
+		return err
+	}
+	return nil
+}
+
+// remove persists the decommissioning of a burrow before it is acknowledged
+func (sh *shard) remove(b Burrow) error {
+	e := Event{At: sh.clock.Now(), Type: EventRemoved, Shard: sh.index, Burrow: &b, Gophers: b.Occupants}
+	if err := sh.append(e); err != nil {
+		return err
+	}
+	if err := sh.store.Delete(b.Name); err != nil {
+		sh.abort(e)
+		return err
+	}
+	return nil
+}
+
+// abort cancels the last event of the shard, a change that was logged but rejected
+func (sh *shard) abort(e Event) {
+	sh.record(Event{At: sh.clock.Now(), Type: EventAborted, Shard: sh.index, Burrow: e.Burrow})
+}
+
+// checkpoint marks the end of the events of the shard that are part of a snapshot
+func (sh *shard) checkpoint() error {
+	err := sh.append(Event{At: sh.clock.Now(), Type: EventCheckpoint, Shard: sh.index})
+	if err != nil {
+		sh.lg.Error("checkpoint not recorded", "shard", sh.index, "error", err.Error())
+	}
+	return err
+}
+
+// append writes the events to the log, after the aging of the burrows that was not logged yet
+func (sh *shard) append(events ...Event) error {
+	if sh.unlogged.ticks > 0 {
+		at := sh.clock.Now()
+		if len(events) > 0 {
+			at = events[0].At
+		}
+		aged := Event{At: at, Type: EventAged, Shard: sh.index, Minutes: sh.unlogged.step, Ticks: sh.unlogged.ticks}
+		events = append([]Event{aged}, events...)
+	}
+	if err := sh.events.Append(events...); err != nil {
+		return err
+	}
+	*sh.unlogged = aging{}
+	return nil
+}
+
+// added persists new burrows of the shard
+func (sh *shard) added(burrows []Burrow) {
+	now := sh.clock.Now()
+	events := make([]Event, len(burrows))
+	for i := range burrows {
+		events[i] = Event{At: now, Type: EventAdded, Shard: sh.index, Burrow: &burrows[i]}
+	}
+	sh.record(events...)
+	sh.save(now, burrows...)
+}
+
+// record appends events that can't be rejected anymore, like the passing of time, to the event log
+func (sh *shard) record(events ...Event) {
+	if err := sh.append(events...); err != nil {
+		sh.lg.Error("events not recorded", "count", len(events), "error", err.Error())
+	}
+}
+
+// save writes changes that can't be rejected anymore, like a collapse, to the store
+func (sh *shard) save(at time.Time, burrows ...Burrow) {
+	if len(burrows) == 0 {
+		return
+	}
+	if err := sh.store.Save(at, burrows...); err != nil {
+		sh.lg.Error("burrows not saved", "count", len(burrows), "error", err.Error())
+	}
+}
@@ -0,0 +1,432 @@
+package burrows
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/textproto"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReportSink delivers the reports of a schedule somewhere.
+type ReportSink interface {
+	// Send delivers the report encoded in every format.
+	Send(ctx context.Context, r Report, encoders []ReportEncoder) error
+}
+
+// The types of the sinks
+const (
+	SinkFile    = "file"
+	SinkStdout  = "stdout"
+	SinkWebhook = "webhook"
+	SinkEmail   = "email"
+)
+
+// SinkConfig configures a sink of a report schedule. Only the fields of its type are used.
+type SinkConfig struct {
+	// Type is file, stdout, webhook or email
+	Type string `yaml:"type"`
+
+	// Dir of the report files. The directory of the schedule by default
+	Dir string `yaml:"dir"`
+
+	// URL the webhook posts the reports to, one request per format
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"`
+	// Retries of a request that failed for a network error or a 5xx or 429 status, 3 by default.
+	// Backoff (1s by default) is the delay before the first retry, it doubles after every retry
+	Retries *int          `yaml:"retries"`
+	Backoff time.Duration `yaml:"backoff"`
+
+	// SMTP is the host:port of the mail server. The report is the body in the first format, the other formats are attached
+	SMTP     string   `yaml:"smtp"`
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+	Subject  string   `yaml:"subject"`
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"`
+}
+
+// Validate checks that the sink has what its type needs.
+func (c SinkConfig) Validate() error {
+	switch c.Type {
+	case SinkFile, SinkStdout:
+	case SinkWebhook:
+		u, err := url.Parse(c.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("webhook sink needs an http or https url, got: %q", c.URL)
+		}
+		if c.Retries != nil && *c.Retries < 0 {
+			return errors.New("webhook sink has negative retries")
+		}
+	case SinkEmail:
+		if _, _, err := net.SplitHostPort(c.SMTP); err != nil {
+			return fmt.Errorf("email sink needs the host:port of the smtp server, got: %q", c.SMTP)
+		}
+		if c.From == "" || len(c.To) == 0 {
+			return errors.New("email sink needs a sender and recipients")
+		}
+	default:
+		return fmt.Errorf("unknown sink: %q", c.Type)
+	}
+	return nil
+}
+
+// MarshalJSON describes the sink without its credentials: the URL of a webhook is reduced to its host,
+// the headers and the password are left out.
+func (c SinkConfig) MarshalJSON() ([]byte, error) {
+	var target string
+	switch c.Type {
+	case SinkFile:
+		target = c.Dir
+	case SinkWebhook:
+		if u, err := url.Parse(c.URL); err == nil {
+			target = u.Scheme + "://" + u.Host
+		}
+	case SinkEmail:
+		target = strings.Join(c.To, ", ")
+	}
+	return json.Marshal(struct {
+		Type   string `json:"type"`
+		Target string `json:"target,omitempty"`
+	}{Type: c.Type, Target: target})
+}
+
+// NewReportSink creates the sink of the configuration.
+// File sinks write to the directory of `files` unless they have their own, with the retention of `files`.
+func NewReportSink(logger *slog.Logger, c SinkConfig, files ReportFiles) (ReportSink, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	switch c.Type {
+	case SinkFile:
+		if c.Dir != "" {
+			files.Dir = c.Dir
+		}
+		return &FileSink{lg: logger, Files: files}, nil
+	case SinkStdout:
+		return NewWriterSink(os.Stdout), nil
+	case SinkWebhook:
+		s := &WebhookSink{URL: c.URL, Headers: c.Headers, Retries: 3, Backoff: c.Backoff, Client: &http.Client{Timeout: 30 * time.Second}}
+		if c.Retries != nil {
+			s.Retries = *c.Retries
+		}
+		if s.Backoff <= 0 {
+			s.Backoff = time.Second
+		}
+		return s, nil
+	default:
+		s := &EmailSink{Addr: c.SMTP, From: c.From, To: c.To, Subject: c.Subject}
+		if c.Username != "" {
+			host, _, _ := net.SplitHostPort(c.SMTP)
+			s.Auth = smtp.PlainAuth("", c.Username, c.Password, host)
+		}
+		return s, nil
+	}
+}
+
+// FileSink writes the reports to a directory, then compresses and removes the old ones.
+// Failures to compress or remove are only logged, the next report tries again.
+type FileSink struct {
+	lg    *slog.Logger
+	Files ReportFiles
+}
+
+func (s *FileSink) Send(_ context.Context, r Report, encoders []ReportEncoder) error {
+	paths, err := s.Files.Write(r, encoders)
+	for _, p := range paths {
+		s.lg.Info("report generated", "filename", p)
+	}
+	if err != nil {
+		return err
+	}
+
+	compressed, err := s.Files.Compress(r.Taken)
+	for _, p := range compressed {
+		s.lg.Debug("report compressed", "filename", p)
+	}
+	if err != nil {
+		s.lg.Error("reports not compressed", "dir", s.Files.Dir, "error", err.Error())
+	}
+
+	removed, err := s.Files.Prune(r.Taken)
+	for _, p := range removed {
+		s.lg.Info("report removed", "filename", p)
+	}
+	if err != nil {
+		s.lg.Error("reports not removed", "dir", s.Files.Dir, "error", err.Error())
+	}
+	return nil
+}
+
+// WriterSink writes the reports one after the other, every format ends with a new line.
+type WriterSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterSink writes the reports to w
+func NewWriterSink(w io.Writer) *WriterSink { return &WriterSink{w: w} }
+
+func (s *WriterSink) Send(_ context.Context, r Report, encoders []ReportEncoder) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, enc := range encoders {
+		var buf bytes.Buffer
+		if err := enc.Encode(&buf, r); err != nil {
+			return err
+		}
+		if !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+			buf.WriteByte('\n')
+		}
+		if _, err := s.w.Write(buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WebhookSink posts every format of a report to a URL, with the content type of the format.
+// Requests that fail for a network error, a 5xx or a 429 status are retried with an exponential backoff.
+type WebhookSink struct {
+	URL     string
+	Headers map[string]string
+	Retries int
+	Backoff time.Duration
+	Client  *http.Client
+}
+
+func (s *WebhookSink) Send(ctx context.Context, r Report, encoders []ReportEncoder) error {
+	for _, enc := range encoders {
+		var buf bytes.Buffer
+		if err := enc.Encode(&buf, r); err != nil {
+			return err
+		}
+		if err := s.post(ctx, enc.ContentType(), buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *WebhookSink) post(ctx context.Context, contentType string, body []byte) error {
+	backoff := s.Backoff
+	for attempt := 0; ; attempt++ {
+		err := s.postOnce(ctx, contentType, body)
+		var permanent permanentError
+		if err == nil || errors.As(err, &permanent) || attempt >= s.Retries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// permanentError is a failure that a retry would not fix
+type permanentError struct{ error }
+
+func (s *WebhookSink) postOnce(ctx context.Context, contentType string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return permanentError{err}
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range s.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	switch {
+	case resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	default:
+		return permanentError{fmt.Errorf("webhook responded %s", resp.Status)}
+	}
+}
+
+// emailTimeout limits the exchange with the mail server, the context may end it earlier
+const emailTimeout = 30 * time.Second
+
+// EmailSink mails the reports. The body is the report in the first format, the other formats are attached.
+// The exchange with the mail server ends with the context.
+type EmailSink struct {
+	// Addr is the host:port of the SMTP server
+	Addr    string
+	Auth    smtp.Auth
+	From    string
+	To      []string
+	Subject string
+}
+
+func (s *EmailSink) Send(ctx context.Context, r Report, encoders []ReportEncoder) error {
+	if len(encoders) == 0 {
+		return nil
+	}
+	msg, err := s.message(r, encoders)
+	if err != nil {
+		return err
+	}
+	if err := s.send(ctx, msg); err != nil {
+		if ctx.Err() != nil {
+			return errors.Join(err, ctx.Err())
+		}
+		return err
+	}
+	return nil
+}
+
+// send delivers the message the way `smtp.SendMail` does, on a connection that is closed when the context is done
+func (s *EmailSink) send(ctx context.Context, msg []byte) error {
+	host, _, err := net.SplitHostPort(s.Addr)
+	if err != nil {
+		return err
+	}
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	if err := conn.SetDeadline(time.Now().Add(emailTimeout)); err != nil {
+		conn.Close()
+		return err
+	}
+	// the context closes the connection rather than setting its deadline, so that its error is set when the exchange fails
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if s.Auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("smtp: server doesn't support AUTH")
+		}
+		if err := c.Auth(s.Auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(s.From); err != nil {
+		return err
+	}
+	for _, to := range s.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// message builds a multipart message with the report in every format
+func (s *EmailSink) message(r Report, encoders []ReportEncoder) ([]byte, error) {
+	subject := s.Subject
+	if subject == "" {
+		subject = "Burrows report of " + r.Taken.Format("2006-01-02 15:04 MST")
+	}
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+
+	for i, enc := range encoders {
+		var buf bytes.Buffer
+		if err := enc.Encode(&buf, r); err != nil {
+			return nil, err
+		}
+
+		h := textproto.MIMEHeader{}
+		h.Set("Content-Type", enc.ContentType())
+		if i == 0 {
+			h.Set("Content-Transfer-Encoding", "quoted-printable")
+			h.Set("Content-Disposition", "inline")
+			pw, err := mw.CreatePart(h)
+			if err != nil {
+				return nil, err
+			}
+			qw := quotedprintable.NewWriter(pw)
+			if _, err := qw.Write(buf.Bytes()); err != nil {
+				return nil, err
+			}
+			if err := qw.Close(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		name := "burrows_" + r.Taken.Format(reportTimeFormat) + "." + enc.Extension()
+		h.Set("Content-Transfer-Encoding", "base64")
+		h.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+		pw, err := mw.CreatePart(h)
+		if err != nil {
+			return nil, err
+		}
+		// lines of base64 are limited to 76 characters
+		encoded := base64.StdEncoding.EncodeToString(buf.Bytes())
+		for len(encoded) > 76 {
+			fmt.Fprintf(pw, "%s\r\n", encoded[:76])
+			encoded = encoded[76:]
+		}
+		fmt.Fprintf(pw, "%s\r\n", encoded)
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", s.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(s.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", r.Taken.Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", mw.Boundary())
+	msg.Write(body.Bytes())
+
+	return msg.Bytes(), nil
+}
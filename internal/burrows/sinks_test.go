@@ -0,0 +1,326 @@
+package burrows
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testEncoders(t *testing.T, formats ...string) []ReportEncoder {
+	t.Helper()
+	var encoders []ReportEncoder
+	for _, f := range formats {
+		e, err := LookupReportEncoder(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		encoders = append(encoders, e)
+	}
+	return encoders
+}
+
+func TestFileSink(t *testing.T) {
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	dir := t.TempDir()
+	sink, err := NewReportSink(logger, SinkConfig{Type: SinkFile, Dir: dir}, ReportFiles{Dir: "elsewhere", Keep: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range 3 {
+		r := testReport
+		r.Taken = r.Taken.Add(time.Duration(i) * time.Hour)
+		if err := sink.Send(context.Background(), r, testEncoders(t, "json")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := ReportFiles{Dir: dir}.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || !files[0].Taken.Equal(testReport.Taken.Add(2*time.Hour)) {
+		t.Errorf("the sink should keep the newest report in its directory. got: %v", files)
+	}
+}
+
+func TestWriterSink(t *testing.T) {
+
+	var buf bytes.Buffer
+	if err := NewWriterSink(&buf).Send(context.Background(), testReport, testEncoders(t, "text", "json")); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "TotalDepth") || !strings.Contains(buf.String(), "\n{") || !strings.HasSuffix(buf.String(), "}\n") {
+		t.Errorf("the reports should follow each other. got:\n%s", buf.String())
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+
+	var requests atomic.Int32
+	var failures int32
+	srvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := requests.Add(1)
+		if r.Header.Get("Content-Type") != "application/json" || r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var decoded Report
+		if err := json.NewDecoder(r.Body).Decode(&decoded); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if n <= atomic.LoadInt32(&failures) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srvr.Close()
+
+	send := func(retries int, url string) error {
+		sink := &WebhookSink{URL: url, Headers: map[string]string{"Authorization": "Bearer token"}, Retries: retries, Backoff: time.Millisecond, Client: srvr.Client()}
+		return sink.Send(context.Background(), testReport, testEncoders(t, "json"))
+	}
+
+	scenarios := []struct {
+		name     string
+		failures int32
+		retries  int
+		url      string
+		fails    bool
+		requests int32
+	}{
+		{name: "delivered", retries: 3, requests: 1},
+		{name: "retried", failures: 2, retries: 3, requests: 3},
+		{name: "too many failures", failures: 5, retries: 2, fails: true, requests: 3},
+		{name: "client error is not retried", retries: 3, url: "/nowhere", requests: 1},
+	}
+
+	for _, s := range scenarios {
+		requests.Store(0)
+		atomic.StoreInt32(&failures, s.failures)
+		err := send(s.retries, srvr.URL+s.url)
+		if (err != nil) != s.fails {
+			t.Errorf("%s: unexpected error: %v", s.name, err)
+		}
+		if requests.Load() != s.requests {
+			t.Errorf("%s: wrong number of requests. expected: %d, got: %d", s.name, s.requests, requests.Load())
+		}
+	}
+
+	bad := &WebhookSink{URL: srvr.URL, Retries: 3, Backoff: time.Millisecond, Client: srvr.Client()}
+	requests.Store(0)
+	atomic.StoreInt32(&failures, 0)
+	if err := bad.Send(context.Background(), testReport, testEncoders(t, "json")); err == nil || requests.Load() != 1 {
+		t.Errorf("a refused request should fail without retries. got: %v after %d requests", err, requests.Load())
+	}
+}
+
+// smtpServer is a local stand-in for a mail server. It accepts every message and sends it to the channel
+func smtpServer(t *testing.T) (string, <-chan []byte) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	messages := make(chan []byte, 1)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				reply := func(s string) { io.WriteString(conn, s+"\r\n") }
+
+				reply("220 localhost ready")
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					cmd := strings.ToUpper(strings.TrimSpace(line))
+					switch {
+					case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+						reply("250 localhost")
+					case cmd == "DATA":
+						reply("354 go ahead")
+						var msg bytes.Buffer
+						for {
+							l, err := r.ReadString('\n')
+							if err != nil {
+								return
+							}
+							if l == ".\r\n" {
+								break
+							}
+							msg.WriteString(strings.TrimPrefix(l, "."))
+						}
+						messages <- msg.Bytes()
+						reply("250 queued")
+					case cmd == "QUIT":
+						reply("221 bye")
+						return
+					default:
+						reply("250 ok")
+					}
+				}
+			}()
+		}
+	}()
+
+	return l.Addr().String(), messages
+}
+
+func TestEmailSink(t *testing.T) {
+
+	addr, messages := smtpServer(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	sink, err := NewReportSink(logger, SinkConfig{Type: SinkEmail, SMTP: addr, From: "burrows@example.com", To: []string{"oncall@example.com", "ops@example.com"}}, ReportFiles{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Send(context.Background(), testReport, testEncoders(t, "markdown", "csv")); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(<-messages))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Header.Get("To") != "oncall@example.com, ops@example.com" || msg.Header.Get("Subject") != "Burrows report of 2024-03-01 10:00 UTC" {
+		t.Errorf("wrong headers. got: %v", msg.Header)
+	}
+
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+
+	body, err := mr.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := io.ReadAll(body)
+	if body.Header.Get("Content-Type") != "text/markdown; charset=utf-8" || !strings.Contains(string(content), "| Metric | Value | Burrow |") {
+		t.Errorf("the body should be the report in the first format. got: %s\n%s", body.Header, content)
+	}
+
+	attachment, err := mr.NextPart()
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ = io.ReadAll(base64.NewDecoder(base64.StdEncoding, attachment))
+	if attachment.FileName() != "burrows_20240301_100000.csv" || !strings.HasPrefix(string(content), "taken,") {
+		t.Errorf("the other formats should be attached. got: %s\n%s", attachment.Header, content)
+	}
+}
+
+func TestEmailSinkContext(t *testing.T) {
+
+	// a mail server that accepts connections and never answers
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(io.Discard, conn)
+			}()
+		}
+	}()
+
+	sink := &EmailSink{Addr: l.Addr().String(), From: "burrows@example.com", To: []string{"oncall@example.com"}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := sink.Send(ctx, testReport, testEncoders(t, "json")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("a server that does not answer should fail with the deadline of the context. got: %v", err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	if err := sink.Send(ctx, testReport, testEncoders(t, "json")); !errors.Is(err, context.Canceled) {
+		t.Errorf("a cancelled context should stop the mail. got: %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("the mail should stop with the context. took: %v", elapsed)
+	}
+}
+
+func TestSinkConfig(t *testing.T) {
+
+	for _, invalid := range []SinkConfig{
+		{Type: "pigeon"},
+		{Type: SinkWebhook, URL: "ftp://example.com"},
+		{Type: SinkWebhook, URL: "https://example.com", Retries: new(int)},
+		{Type: SinkEmail, SMTP: "mail.example.com", From: "a@example.com", To: []string{"b@example.com"}},
+		{Type: SinkEmail, SMTP: "mail.example.com:25", From: "a@example.com"},
+	} {
+		if invalid.Retries != nil {
+			*invalid.Retries = -1
+		}
+		if err := invalid.Validate(); err == nil {
+			t.Errorf("the sink should be refused: %+v", invalid)
+		}
+	}
+
+	b, err := json.Marshal(SinkConfig{Type: SinkWebhook, URL: "https://chat.example.com/hooks/secret-token", Headers: map[string]string{"Authorization": "Bearer secret"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"type":"webhook","target":"https://chat.example.com"}` {
+		t.Errorf("the credentials should not be listed. got: %s", b)
+	}
+
+	schedules, err := ReadReportSchedules(strings.NewReader(`
+schedules:
+  - name: daily
+    cron: "@daily"
+    sinks:
+      - type: stdout
+      - type: webhook
+        url: https://chat.example.com/hooks/burrows
+        retries: 5
+        backoff: 2s
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sinks := schedules[0].Sinks; len(sinks) != 2 || *sinks[1].Retries != 5 || sinks[1].Backoff != 2*time.Second {
+		t.Errorf("wrong sinks. got: %+v", sinks)
+	}
+	if _, err := ReadReportSchedules(strings.NewReader("schedules:\n  - name: a\n    cron: '@daily'\n    sinks: [{type: email}]")); err == nil {
+		t.Errorf("invalid sinks should be refused")
+	}
+}
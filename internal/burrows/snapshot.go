@@ -0,0 +1,150 @@
+package burrows
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrChecksum is returned when a snapshot does not match its checksum, ex: the file was truncated or edited.
+var ErrChecksum = errors.New("snapshot does not match its checksum")
+
+// Snapshot is the status of all the burrows at a point in time.
+// The manager writes one out periodically and when it shuts down. The data files are snapshots too.
+// Snapshots of older schema versions are migrated when they are read (see `migrations`).
+type Snapshot struct {
+	// Version of the schema, set by `WriteSnapshot`
+	Version  int       `json:"version"`
+	Taken    time.Time `json:"taken"`
+	Metadata Metadata  `json:"metadata"`
+	Burrows  []Burrow  `json:"burrows"`
+	// Saved is when every burrow was saved, if the burrows were not all saved when the snapshot was taken,
+	// ex: the stores only save the burrows that changed. See `Align`
+	Saved map[string]time.Time `json:"saved,omitempty"`
+	// Checksum is the sha256 of the burrows, set by `WriteSnapshot`
+	Checksum string `json:"checksum,omitempty"`
+}
+
+// Metadata describes where a snapshot comes from.
+type Metadata struct {
+	// Generator is the program that wrote the snapshot, ex: `burrows v1.2.0`
+	Generator string `json:"generator,omitempty"`
+	// MigratedFrom is the schema version of the snapshot before it was migrated
+	MigratedFrom int `json:"migratedFrom,omitempty"`
+}
+
+// Generator names the program in the metadata of the snapshots it writes.
+var Generator = "burrows"
+
+// checksum hashes the JSON encoding of the burrows
+func (s Snapshot) checksum() (string, error) {
+	b, err := json.Marshal(s.Burrows)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// WriteSnapshot encodes the snapshot in the current schema version, together with its checksum.
+func WriteSnapshot(w io.Writer, s Snapshot) error {
+	sum, err := s.checksum()
+	if err != nil {
+		return err
+	}
+	s.Version = SchemaVersion
+	s.Checksum = sum
+	if s.Metadata.Generator == "" {
+		s.Metadata.Generator = Generator
+	}
+	return json.NewEncoder(w).Encode(s)
+}
+
+// WriteSnapshotFile replaces the file with the snapshot atomically,
+// a crash leaves either the old or the new version behind.
+func WriteSnapshotFile(path string, s Snapshot) error {
+	return writeFileAtomic(path, func(w io.Writer) error { return WriteSnapshot(w, s) })
+}
+
+// ReadSnapshot decodes a snapshot, migrates it to the current schema version and verifies its checksum, if it has one.
+// Snapshots of older versions have no timestamp, in which case the time the snapshot was taken is unknown (zero).
+func ReadSnapshot(r io.Reader) (Snapshot, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	doc, version, err := decodeDocument(b)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	if err := migrate(doc, version); err != nil {
+		return Snapshot{}, err
+	}
+	if b, err = json.Marshal(doc); err != nil {
+		return Snapshot{}, err
+	}
+
+	var s Snapshot
+	if err := json.Unmarshal(b, &s); err != nil {
+		return s, err
+	}
+	s.Version = SchemaVersion
+	if version < SchemaVersion {
+		s.Metadata.MigratedFrom = version
+	}
+	if s.Checksum == "" {
+		return s, nil
+	}
+
+	sum, err := s.checksum()
+	if err != nil {
+		return s, err
+	}
+	if sum != s.Checksum {
+		return s, ErrChecksum
+	}
+
+	return s, nil
+}
+
+// CatchUp ages all the burrows by the time that passed between taking the snapshot and `now`,
+// as if the manager never stopped. One minute in the life of a burrow lasts one `tact`.
+// It returns the number of minutes the burrows aged.
+// Snapshots without a timestamp are left untouched.
+func (s *Snapshot) CatchUp(now time.Time, tact time.Duration) int {
+	mins := CatchUpMinutes(s.Taken, now, tact)
+	if mins == 0 {
+		return 0
+	}
+
+	for i := range s.Burrows {
+		s.Burrows[i].AgeBy(mins)
+	}
+	s.Taken = s.Taken.Add(time.Duration(mins) * tact)
+
+	return mins
+}
+
+// Align ages the burrows that were saved before the snapshot was taken by the time that passed until then,
+// as if they had been saved along with the others. One minute in the life of a burrow lasts one `tact`.
+func (s *Snapshot) Align(tact time.Duration) {
+	for i := range s.Burrows {
+		if saved, ok := s.Saved[s.Burrows[i].Name]; ok {
+			s.Burrows[i].AgeBy(CatchUpMinutes(saved, s.Taken, tact))
+		}
+	}
+	s.Saved = nil
+}
+
+// CatchUpMinutes returns how many minutes burrows age between `taken` and `now`.
+// It is 0 if the time the burrows were taken is unknown (zero).
+func CatchUpMinutes(taken, now time.Time, tact time.Duration) int {
+	if taken.IsZero() || !now.After(taken) {
+		return 0
+	}
+	return int(now.Sub(taken) / tact)
+}
@@ -0,0 +1,92 @@
+package burrows
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadSnapshot(t *testing.T) {
+
+	scenarios := []struct {
+		name  string
+		data  string
+		taken time.Time
+		count int
+	}{
+		{name: "list of burrows", data: `[{"name": "one"}, {"name": "two"}]`, count: 2},
+		{name: "snapshot", data: `{"taken": "2024-03-01T10:00:00Z", "burrows": [{"name": "one"}]}`, taken: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC), count: 1},
+		{name: "empty snapshot", data: ` {"taken": "2024-03-01T10:00:00Z"}`, taken: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)},
+	}
+
+	for _, s := range scenarios {
+		t.Run(s.name, func(t *testing.T) {
+			t.Parallel()
+
+			snap, err := ReadSnapshot(strings.NewReader(s.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !snap.Taken.Equal(s.taken) || len(snap.Burrows) != s.count {
+				t.Errorf("wrong snapshot. expected %d burrows taken at %v, got: %+v", s.count, s.taken, snap)
+			}
+		})
+	}
+}
+
+func TestSnapshotCatchUp(t *testing.T) {
+
+	taken := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	weekend := taken.Add(48*time.Hour + 30*time.Second)
+
+	snap := Snapshot{
+		Taken: taken,
+		Burrows: []Burrow{
+			{Name: "free", Depth: 1, AgeInMin: 10},
+			{Name: "occupied", Depth: 1, AgeInMin: 10, Occupants: []string{"gopher"}},
+			{Name: "old", Depth: 1, AgeInMin: maxAgeInMin - 60},
+		},
+	}
+
+	if mins := snap.CatchUp(weekend, time.Minute); mins != 48*60 {
+		t.Errorf("wrong downtime. expected: %d minutes, got: %d", 48*60, mins)
+	}
+
+	if snap.Burrows[0].AgeInMin != 10+48*60 || snap.Burrows[0].Depth != 1 {
+		t.Errorf("free burrow should only age. got: %+v", snap.Burrows[0])
+	}
+	if snap.Burrows[1].AgeInMin != 10+48*60 || snap.Burrows[1].Depth <= 1 {
+		t.Errorf("occupied burrow should age and grow deeper. got: %+v", snap.Burrows[1])
+	}
+	if !snap.Burrows[2].IsCollapsed() {
+		t.Errorf("old burrow should have collapsed during the downtime. got: %+v", snap.Burrows[2])
+	}
+
+	if mins := (&Snapshot{Burrows: snap.Burrows}).CatchUp(weekend, time.Minute); mins != 0 {
+		t.Errorf("snapshots without timestamp should not catch up. got: %d minutes", mins)
+	}
+}
+
+func TestSnapshotChecksum(t *testing.T) {
+
+	snap := Snapshot{
+		Taken:   time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+		Burrows: []Burrow{{Name: "one", Depth: 1.5, Labels: map[string]string{"site": "north"}}},
+	}
+
+	var buf strings.Builder
+	if err := WriteSnapshot(&buf, snap); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ReadSnapshot(strings.NewReader(buf.String())); err != nil {
+		t.Errorf("the snapshot should match its checksum. got: %v", err)
+	}
+
+	tampered := strings.Replace(buf.String(), `"depth":1.5`, `"depth":2.5`, 1)
+	if _, err := ReadSnapshot(strings.NewReader(tampered)); !errors.Is(err, ErrChecksum) {
+		t.Errorf("expected: %v, got: %v", ErrChecksum, err)
+	}
+}
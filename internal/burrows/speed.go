@@ -0,0 +1,101 @@
+package burrows
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Speed tells how fast the burrows age.
+type Speed struct {
+	// Tact is the real duration of one minute in the life of a burrow
+	Tact time.Duration
+	// Paused burrows don't age at all
+	Paused bool
+}
+
+type speedJSON struct {
+	Tact   string `json:"tact"`
+	Paused bool   `json:"paused"`
+}
+
+func (s Speed) MarshalJSON() ([]byte, error) {
+	return json.Marshal(speedJSON{Tact: s.Tact.String(), Paused: s.Paused})
+}
+
+func (s *Speed) UnmarshalJSON(b []byte) error {
+	var v speedJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	tact, err := time.ParseDuration(v.Tact)
+	if err != nil {
+		return err
+	}
+	*s = Speed{Tact: tact, Paused: v.Paused}
+	return nil
+}
+
+// speedRequest asks the manager to change the speed or to age all the burrows at once.
+// The manager applies the change between two ticks and responds with the new speed
+type speedRequest struct {
+	change   func(s *Speed) error
+	advance  int
+	response chan speedResponse
+}
+
+type speedResponse struct {
+	speed Speed
+	err   error
+}
+
+// Speed returns how fast the burrows age at the moment.
+func (m *manager) Speed() Speed {
+	s, _ := m.changeSpeed(speedRequest{})
+	return s
+}
+
+// Pause stops the aging of all the burrows until `Resume` is called.
+func (m *manager) Pause() Speed {
+	s, _ := m.changeSpeed(speedRequest{change: func(s *Speed) error {
+		s.Paused = true
+		return nil
+	}})
+	return s
+}
+
+// Resume restarts the aging of the burrows after a `Pause`.
+func (m *manager) Resume() Speed {
+	s, _ := m.changeSpeed(speedRequest{change: func(s *Speed) error {
+		s.Paused = false
+		return nil
+	}})
+	return s
+}
+
+// SetTact changes how often all the burrows age by one minute.
+func (m *manager) SetTact(tact time.Duration) (Speed, error) {
+	return m.changeSpeed(speedRequest{change: func(s *Speed) error {
+		if tact <= 0 {
+			return errors.New("the tact should be positive")
+		}
+		s.Tact = tact
+		return nil
+	}})
+}
+
+// Advance fast-forwards all the burrows by the given time of their life, even if the aging is paused.
+func (m *manager) Advance(d time.Duration) (Speed, error) {
+	mins := int(d / time.Minute)
+	if mins <= 0 {
+		return Speed{}, errors.New("the burrows can only advance by whole minutes")
+	}
+	return m.changeSpeed(speedRequest{advance: mins})
+}
+
+func (m *manager) changeSpeed(req speedRequest) (Speed, error) {
+	req.response = make(chan speedResponse, 1)
+	m.speed <- req
+	resp := <-req.response
+	return resp.speed, resp.err
+}
@@ -0,0 +1,30 @@
+package burrows
+
+import "time"
+
+// Store persists the burrows so that no change is lost if the process stops unexpectedly.
+// Burrows are identified by their name. Implementations are safe for concurrent use.
+type Store interface {
+	// Load returns all the stored burrows, as a snapshot taken at the last save.
+	// Burrows are only saved when they change, the snapshot has the time every burrow was saved to `Align` them.
+	Load() (Snapshot, error)
+	// Save stores the burrows as they were at time `at`, replacing the stored burrows with the same names.
+	// Either all or none of the burrows are saved.
+	Save(at time.Time, burrows ...Burrow) error
+	// Delete removes the burrows with the given names. Unknown names are ignored.
+	Delete(names ...string) error
+	Close() error
+}
+
+// NopStore is used when the burrows should not be persisted
+var NopStore Store = nopStore{}
+
+type nopStore struct{}
+
+func (nopStore) Load() (Snapshot, error) { return Snapshot{}, nil }
+
+func (nopStore) Save(_ time.Time, _ ...Burrow) error { return nil }
+
+func (nopStore) Delete(_ ...string) error { return nil }
+
+func (nopStore) Close() error { return nil }
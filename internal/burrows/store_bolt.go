@@ -0,0 +1,139 @@
+package burrows
+
+import (
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	burrowsBucket = []byte("burrows")
+	// savedBucket has the time every burrow was saved and metaBucket the time of the last save.
+	// Databases of older versions don't have them
+	savedBucket = []byte("saved")
+	metaBucket  = []byte("meta")
+	takenKey    = []byte("taken")
+)
+
+// BoltStore keeps the burrows in an embedded bolt database, one record per burrow.
+// Saving a batch of burrows is a single transaction, so it scales to large inventories.
+// The burrows are loaded sorted by name, the time of their last save is kept in a separate bucket.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore opens the database file, creating it if it does not exist.
+func NewBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{burrowsBucket, savedBucket, metaBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+func (s *BoltStore) Load() (Snapshot, error) {
+	snapshot := Snapshot{Saved: make(map[string]time.Time)}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		err := tx.Bucket(burrowsBucket).ForEach(func(_, v []byte) error {
+			var b Burrow
+			if err := json.Unmarshal(v, &b); err != nil {
+				return err
+			}
+			snapshot.Burrows = append(snapshot.Burrows, b)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		err = tx.Bucket(savedBucket).ForEach(func(k, v []byte) error {
+			var at time.Time
+			if err := at.UnmarshalText(v); err != nil {
+				return err
+			}
+			snapshot.Saved[string(k)] = at
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if v := tx.Bucket(metaBucket).Get(takenKey); v != nil {
+			return snapshot.Taken.UnmarshalText(v)
+		}
+		return nil
+	})
+	return snapshot, err
+}
+
+func (s *BoltStore) Save(at time.Time, burrows ...Burrow) error {
+	if len(burrows) == 0 {
+		return nil
+	}
+	savedAt, err := at.MarshalText()
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket, saved := tx.Bucket(burrowsBucket), tx.Bucket(savedBucket)
+		for _, b := range burrows {
+			v, err := json.Marshal(b)
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put([]byte(b.Name), v); err != nil {
+				return err
+			}
+			if err := saved.Put([]byte(b.Name), savedAt); err != nil {
+				return err
+			}
+		}
+
+		meta := tx.Bucket(metaBucket)
+		var taken time.Time
+		if v := meta.Get(takenKey); v != nil {
+			if err := taken.UnmarshalText(v); err != nil {
+				return err
+			}
+		}
+		if at.After(taken) {
+			return meta.Put(takenKey, savedAt)
+		}
+		return nil
+	})
+}
+
+func (s *BoltStore) Delete(names ...string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket, saved := tx.Bucket(burrowsBucket), tx.Bucket(savedBucket)
+		for _, name := range names {
+			if err := bucket.Delete([]byte(name)); err != nil {
+				return err
+			}
+			if err := saved.Delete([]byte(name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
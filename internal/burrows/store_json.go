@@ -0,0 +1,143 @@
+package burrows
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// JSONStore keeps all the burrows in a single JSON file.
+// The file is a snapshot, so it can also be used as a data file for `serve --path`.
+// It is taken at the last save and has the time every burrow was saved.
+// Every save rewrites the whole file, which makes it a good fit for small inventories.
+// Larger inventories should use the `BoltStore`.
+type JSONStore struct {
+	path string
+
+	mu      sync.Mutex
+	burrows []Burrow
+	index   map[string]int
+	saved   map[string]time.Time
+	taken   time.Time
+}
+
+// NewJSONStore opens the JSON file, creating it on the first save if it does not exist.
+func NewJSONStore(path string) (*JSONStore, error) {
+	s := &JSONStore{path: path, index: make(map[string]int), saved: make(map[string]time.Time)}
+
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	snapshot, err := ReadSnapshot(f)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range snapshot.Burrows {
+		s.put(b)
+	}
+	for name, at := range snapshot.Saved {
+		s.saved[name] = at
+	}
+	s.taken = snapshot.Taken
+
+	return s, nil
+}
+
+func (s *JSONStore) put(b Burrow) {
+	if i, ok := s.index[b.Name]; ok {
+		s.burrows[i] = b
+		return
+	}
+	s.index[b.Name] = len(s.burrows)
+	s.burrows = append(s.burrows, b)
+}
+
+func (s *JSONStore) Load() (Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.snapshot(), nil
+}
+
+func (s *JSONStore) Save(at time.Time, burrows ...Burrow) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, index := append([]Burrow(nil), s.burrows...), len(s.burrows)
+	saved, taken := maps.Clone(s.saved), s.taken
+	for _, b := range burrows {
+		s.put(b)
+		s.saved[b.Name] = at
+	}
+	if at.After(s.taken) {
+		s.taken = at
+	}
+
+	if err := s.write(); err != nil {
+		// forget the changes, the file still has the previous version
+		s.burrows, s.saved, s.taken = previous, saved, taken
+		for name, i := range s.index {
+			if i >= index {
+				delete(s.index, name)
+			}
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (s *JSONStore) Delete(names ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, saved := s.burrows, s.saved
+	s.burrows = slices.DeleteFunc(slices.Clone(s.burrows), func(b Burrow) bool { return slices.Contains(names, b.Name) })
+	if len(s.burrows) == len(previous) {
+		return nil
+	}
+	s.saved = maps.Clone(saved)
+	for _, name := range names {
+		delete(s.saved, name)
+	}
+
+	if err := s.write(); err != nil {
+		s.burrows, s.saved = previous, saved
+		return err
+	}
+	s.reindex()
+	return nil
+}
+
+// reindex rebuilds the position of every burrow after some were deleted
+func (s *JSONStore) reindex() {
+	clear(s.index)
+	for i, b := range s.burrows {
+		s.index[b.Name] = i
+	}
+}
+
+// snapshot copies the stored burrows
+func (s *JSONStore) snapshot() Snapshot {
+	return Snapshot{Taken: s.taken, Burrows: slices.Clone(s.burrows), Saved: maps.Clone(s.saved)}
+}
+
+// write replaces the file atomically, a crash leaves either the old or the new version behind
+func (s *JSONStore) write() error {
+	return writeFileAtomic(s.path, func(w io.Writer) error {
+		return WriteSnapshot(w, s.snapshot())
+	})
+}
+
+func (s *JSONStore) Close() error { return nil }
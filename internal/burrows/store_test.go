@@ -0,0 +1,135 @@
+package burrows
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStores(t *testing.T) {
+
+	stores := map[string]func(path string) (Store, error){
+		"json": func(path string) (Store, error) { return NewJSONStore(path) },
+		"bolt": func(path string) (Store, error) { return NewBoltStore(path) },
+	}
+
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "burrows."+name)
+
+			s, err := open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			start := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+			if err := s.Save(start, Burrow{Name: "b", Depth: 1}, Burrow{Name: "a", Depth: 2, Labels: map[string]string{"site": "north"}}); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Save(start.Add(time.Minute), Burrow{Name: "b", Depth: 1, Occupants: []string{"gopher"}}); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Save(start.Add(2*time.Minute), Burrow{Name: "c"}); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Delete("c", "unknown"); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			// a new process finds all the changes
+			s, err = open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+
+			snapshot, err := s.Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			loaded := snapshot.Burrows
+			slices.SortFunc(loaded, func(a, b Burrow) int { return strings.Compare(a.Name, b.Name) })
+
+			expected := []Burrow{
+				{Name: "a", Depth: 2, Labels: map[string]string{"site": "north"}},
+				{Name: "b", Depth: 1, Occupants: []string{"gopher"}},
+			}
+			if !reflect.DeepEqual(expected, loaded) {
+				t.Errorf("wrong burrows loaded. expected: %v, got: %v", expected, loaded)
+			}
+
+			// the snapshot is taken at the last save, even of a deleted burrow
+			saved := map[string]time.Time{"a": start, "b": start.Add(time.Minute)}
+			if !snapshot.Taken.Equal(start.Add(2*time.Minute)) || len(snapshot.Saved) != len(saved) {
+				t.Errorf("wrong times of the saves. got: %v, %v", snapshot.Taken, snapshot.Saved)
+			}
+			for name, at := range saved {
+				if !snapshot.Saved[name].Equal(at) {
+					t.Errorf("%s should be saved at %v. got: %v", name, at, snapshot.Saved[name])
+				}
+			}
+		})
+	}
+}
+
+func TestManagerPersistsChanges(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "burrows.db")
+	store, err := NewBoltStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	start := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	m := newTestManager(t, clock, WithStore(store))
+	loadBurrows(m, Burrow{Name: "burrow", Capacity: 2}, Burrow{Name: "old", Occupants: []string{"other"}, AgeInMin: maxAgeInMin - 3})
+
+	if _, err := m.Rentout(context.Background(), Rental{Gophers: []string{"gopher"}}); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Minute)
+	if _, err := m.SetLabels("burrow", map[string]string{"site": "north"}); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(3 * time.Minute)
+	_ = m.CurrentStatus()
+
+	stored, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored.Burrows) != 2 {
+		t.Fatalf("wrong number of stored burrows. expected: 2, got: %d", len(stored.Burrows))
+	}
+
+	// the aging is not saved, only the changes and the collapse
+	b := stored.Burrows[0]
+	if b.AgeInMin != 1 || !slices.Equal(b.Occupants, []string{"gopher"}) || b.Labels["site"] != "north" {
+		t.Errorf("changes not persisted. got: %+v", b)
+	}
+	if !stored.Saved["burrow"].Equal(start.Add(time.Minute)) {
+		t.Errorf("the burrow should be saved when its labels changed. got: %v", stored.Saved["burrow"])
+	}
+	// the shard may handle the tick after the clock moved on
+	collapsedAt := stored.Saved["old"]
+	if old := stored.Burrows[1]; !old.IsCollapsed() || collapsedAt.Before(start.Add(3*time.Minute)) || !stored.Taken.Equal(collapsedAt) {
+		t.Errorf("the collapse should be saved. got: %+v at %v", old, collapsedAt)
+	}
+
+	// the burrows catch up with the last save
+	stored.Align(time.Minute)
+	if b, expected := stored.Burrows[0], 1+int(collapsedAt.Sub(start.Add(time.Minute))/time.Minute); b.AgeInMin != expected {
+		t.Errorf("the burrow should catch up with the collapse. expected age %d, got: %+v", expected, b)
+	}
+}
@@ -0,0 +1,192 @@
+package burrows
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Watcher polls a data file, or a drop-in directory of data files, and reconciles the manager
+// with every file that appeared or changed since the previous poll (see `Manager.Reconcile`).
+// Files are recognized by their size and modification time. A file that could not be read is
+// retried once it changes again, so a file that is still being copied is picked up when it is complete.
+type Watcher struct {
+	lg      *slog.Logger
+	manager Manager
+	path    string
+	// format of the files, detected from the extension when empty
+	format Format
+
+	// seen are the files that were already reconciled
+	seen map[string]fileStamp
+}
+
+type fileStamp struct {
+	size int64
+	// modTime in nanoseconds since the epoch
+	modTime int64
+}
+
+// NewWatcher watches the file or directory at `path`.
+// In a directory, hidden files and files without the extension of a data file are ignored.
+func NewWatcher(logger *slog.Logger, manager Manager, path string, format Format) *Watcher {
+	return &Watcher{
+		lg:      logger,
+		manager: manager,
+		path:    path,
+		format:  format,
+		seen:    make(map[string]fileStamp),
+	}
+}
+
+// Skip takes the files as they are now as reconciled, only later changes are picked up.
+func (w *Watcher) Skip() error {
+	files, err := w.files()
+	if err != nil {
+		return err
+	}
+	for path, stamp := range files {
+		w.seen[path] = stamp
+	}
+	return nil
+}
+
+// Run polls at every tick of the ticker until the context is done.
+// The first poll happens right away.
+func (w *Watcher) Run(ctx context.Context, ticker Ticker) {
+	defer ticker.Stop()
+
+	for {
+		if _, err := w.Poll(); err != nil {
+			w.lg.Error("data not reconciled", "path", w.path, "error", err.Error())
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+// Poll reconciles the manager with the files that are new or changed. Files are read in the order of their names.
+// The errors of all the files that could not be read are joined.
+func (w *Watcher) Poll() (Reconciliation, error) {
+	var rec Reconciliation
+
+	files, err := w.files()
+	if err != nil {
+		return rec, err
+	}
+	for path := range w.seen {
+		if _, ok := files[path]; !ok {
+			delete(w.seen, path)
+		}
+	}
+
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var errs []error
+	for _, path := range paths {
+		if w.seen[path] == files[path] {
+			continue
+		}
+		w.seen[path] = files[path]
+
+		r, err := w.reconcile(path)
+		rec.Merge(r)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			continue
+		}
+		w.lg.Info("data reconciled", "path", path, "added", len(r.Added), "updated", len(r.Updated), "removed", len(r.Removed))
+	}
+
+	return rec, errors.Join(errs...)
+}
+
+// files returns the data files that are watched
+func (w *Watcher) files() (map[string]fileStamp, error) {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return map[string]fileStamp{w.path: {size: info.Size(), modTime: info.ModTime().UnixNano()}}, nil
+	}
+
+	entries, err := os.ReadDir(w.path)
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]fileStamp)
+	for _, e := range entries {
+		if !e.Type().IsRegular() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		if w.format == "" {
+			if _, err := ParseFormat(strings.TrimPrefix(filepath.Ext(e.Name()), ".")); err != nil {
+				continue
+			}
+		}
+		info, err := e.Info()
+		if err != nil {
+			// removed meanwhile
+			continue
+		}
+		files[filepath.Join(w.path, e.Name())] = fileStamp{size: info.Size(), modTime: info.ModTime().UnixNano()}
+	}
+	return files, nil
+}
+
+// reconcile reads the records of the file in batches, so that large files never have to fit into memory
+func (w *Watcher) reconcile(path string) (Reconciliation, error) {
+	var rec Reconciliation
+
+	format := w.format
+	if format == "" {
+		format = FormatOf(path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return rec, err
+	}
+	defer f.Close()
+
+	dec, err := NewDecoder(f, format)
+	if err != nil {
+		return rec, err
+	}
+
+	var batch []Burrow
+	for {
+		b, err := dec.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return rec, err
+		}
+		batch = append(batch, b)
+		if len(batch) == shardSize {
+			rec.Merge(w.manager.Reconcile(batch))
+			batch = nil
+		}
+	}
+	if len(batch) > 0 {
+		rec.Merge(w.manager.Reconcile(batch))
+	}
+
+	return rec, nil
+}
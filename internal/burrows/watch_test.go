@@ -0,0 +1,105 @@
+package burrows
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestWatcher(t *testing.T) {
+
+	m := newTestManager(t, NewFakeClock(time.Now()))
+	loadBurrows(m,
+		Burrow{Name: "home", Capacity: 2, Occupants: []string{"Gus"}, Depth: 1.5, Width: 1, AgeInMin: 60},
+		Burrow{Name: "old", Capacity: 1, Occupants: []string{"Goldie"}},
+	)
+
+	dir := t.TempDir()
+	w := NewWatcher(slog.New(slog.NewTextHandler(io.Discard, nil)), m, dir, "")
+	drop := func(name, content string, modTime time.Time) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o664); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	drop("monday.csv", "name,capacity,width,labels,removed\nhome,4,1.2,site=north,\nnew,3,1,,\nold,,,,true\n", day)
+	drop("notes.txt", "not a data file", day)
+	rec, err := w.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Reconciliation{Added: []string{"new"}, Updated: []string{"home"}, Removed: []string{"old"}}
+	if !reflect.DeepEqual(expected, rec) {
+		t.Errorf("wrong reconciliation. expected: %+v, got: %+v", expected, rec)
+	}
+
+	status := m.CurrentStatus()
+	names := make([]string, len(status))
+	for i, b := range status {
+		names[i] = b.Name
+	}
+	if !slices.Equal([]string{"home", "new"}, names) {
+		t.Errorf("wrong burrows after the reconciliation. got: %v", names)
+	}
+	// the occupants and the state of the burrow stay untouched
+	home := Burrow{Name: "home", Capacity: 4, Occupants: []string{"Gus"}, Depth: 1.5, Width: 1.2, AgeInMin: 60, Labels: map[string]string{"site": "north"}}
+	if !reflect.DeepEqual(home, status[0]) {
+		t.Errorf("wrong reconciled burrow. expected: %+v, got: %+v", home, status[0])
+	}
+
+	// unchanged files are not read again
+	if rec, err := w.Poll(); err != nil || !rec.Empty() {
+		t.Errorf("nothing should change without new files. got: %+v, error: %v", rec, err)
+	}
+
+	// a broken file is reported and picked up once it is fixed
+	drop("tuesday.ndjson", `{"name": "next"`, day.Add(24*time.Hour))
+	if _, err := w.Poll(); err == nil {
+		t.Errorf("a broken file should be reported")
+	}
+	drop("tuesday.ndjson", `{"name": "next", "capacity": 1}`, day.Add(25*time.Hour))
+	rec, err = w.Poll()
+	if err != nil || !slices.Equal([]string{"next"}, rec.Added) {
+		t.Errorf("the fixed file should add the burrow. got: %+v, error: %v", rec, err)
+	}
+}
+
+func TestWatcherSkip(t *testing.T) {
+
+	m := newTestManager(t, NewFakeClock(time.Now()))
+	path := filepath.Join(t.TempDir(), "burrows.ndjson")
+	if err := os.WriteFile(path, []byte(`{"name": "loaded"}`), 0o664); err != nil {
+		t.Fatal(err)
+	}
+
+	w := NewWatcher(slog.New(slog.NewTextHandler(io.Discard, nil)), m, path, "")
+	if err := w.Skip(); err != nil {
+		t.Fatal(err)
+	}
+	if rec, err := w.Poll(); err != nil || !rec.Empty() {
+		t.Errorf("a skipped file should not be reconciled. got: %+v, error: %v", rec, err)
+	}
+
+	later := time.Now().Add(time.Minute)
+	if err := os.WriteFile(path, []byte(`{"name": "loaded"}`+"\n"+`{"name": "added"}`), 0o664); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	rec, err := w.Poll()
+	if err != nil || !slices.Equal([]string{"loaded", "added"}, rec.Added) {
+		t.Errorf("the changed file should be reconciled. got: %+v, error: %v", rec, err)
+	}
+}
@@ -3,24 +3,91 @@ package http
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/mehix/gopher-burrows/internal/burrows"
 )
 
-func Handler(manager burrows.Manager) http.Handler {
+// HandlerOption changes the default configuration of the handler.
+type HandlerOption func(*handlerConfig)
+
+type handlerConfig struct {
+	history   burrows.ReportHistory
+	schedules func() []burrows.ScheduleStatus
+	dumps     *burrows.Dumps
+}
+
+// WithReportHistory serves the time series of the periodic reports under `/reports`
+func WithReportHistory(h burrows.ReportHistory) HandlerOption {
+	return func(c *handlerConfig) { c.history = h }
+}
+
+// WithDumps compares the burrows of the dumps under `/reports/diff`
+func WithDumps(d burrows.Dumps) HandlerOption {
+	return func(c *handlerConfig) { c.dumps = &d }
+}
+
+// WithSchedules lists the report schedules under `/admin/schedules`
+func WithSchedules(schedules func() []burrows.ScheduleStatus) HandlerOption {
+	return func(c *handlerConfig) { c.schedules = schedules }
+}
+
+func Handler(manager burrows.Manager, opts ...HandlerOption) http.Handler {
+	var cfg handlerConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("OK")) })
 	mux.HandleFunc("GET /", showStatus(manager))
 	mux.HandleFunc("POST /rent", rentBurrow(manager))
+	mux.HandleFunc("PUT /burrows/{name}/labels", setLabels(manager))
+	mux.HandleFunc("POST /burrows/{name}/vacate", vacate(manager))
+	mux.HandleFunc("GET /forecast", showForecast(manager))
+	mux.HandleFunc("GET /export", exportInventory(manager))
+	mux.HandleFunc("GET /report", showReport(manager))
+	mux.HandleFunc("GET /reports", showReportHistory(cfg.history, time.Now))
+	mux.HandleFunc("GET /reports/diff", diffReports(manager, cfg.dumps, cfg.history, time.Now))
+
+	mux.HandleFunc("GET /admin/schedules", showSchedules(cfg.schedules))
+	mux.HandleFunc("GET /admin/speed", changeSpeed(func(_ *http.Request) (burrows.Speed, error) { return manager.Speed(), nil }))
+	mux.HandleFunc("POST /admin/speed/pause", changeSpeed(func(_ *http.Request) (burrows.Speed, error) { return manager.Pause(), nil }))
+	mux.HandleFunc("POST /admin/speed/resume", changeSpeed(func(_ *http.Request) (burrows.Speed, error) { return manager.Resume(), nil }))
+	mux.HandleFunc("POST /admin/speed/tact", changeSpeed(func(r *http.Request) (burrows.Speed, error) {
+		tact, err := time.ParseDuration(r.URL.Query().Get("value"))
+		if err != nil {
+			return burrows.Speed{}, err
+		}
+		return manager.SetTact(tact)
+	}))
+	mux.HandleFunc("POST /admin/speed/advance", changeSpeed(func(r *http.Request) (burrows.Speed, error) {
+		d, err := burrows.ParseDuration(r.URL.Query().Get("by"))
+		if err != nil {
+			return burrows.Speed{}, err
+		}
+		return manager.Advance(d)
+	}))
 	return mux
 }
 
+// showStatus lists all the burrows.
+// The optional `selector` query parameter restricts the list to the burrows with matching labels.
 func showStatus(manager burrows.Manager) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		burrows := manager.CurrentStatus()
+		sel, err := burrows.ParseSelector(r.URL.Query().Get("selector"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		burrows := burrows.Filter(manager.CurrentStatus(), sel)
 
 		w.Header().Set("Content-type", "application/json")
 		if err := json.NewEncoder(w).Encode(burrows); err != nil {
@@ -39,7 +106,14 @@ func rentBurrow(manager burrows.Manager) http.HandlerFunc {
 		allowedTime, cancel := context.WithTimeout(r.Context(), time.Second)
 		defer cancel()
 
-		b, err := manager.Rentout(allowedTime)
+		// the body is optional. without it a single anonymous gopher rents a burrow
+		var rental burrows.Rental
+		if err := json.NewDecoder(r.Body).Decode(&rental); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		b, err := manager.Rentout(allowedTime, rental)
 
 		w.Header().Set("Content-type", "application/json")
 		if err != nil {
@@ -50,3 +124,416 @@ func rentBurrow(manager burrows.Manager) http.HandlerFunc {
 		_ = json.NewEncoder(w).Encode(Response{Burrow: b})
 	}
 }
+
+// setLabels replaces the labels of a burrow with the ones from the request body.
+func setLabels(manager burrows.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var labels map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&labels); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		b, err := manager.SetLabels(r.PathValue("name"), labels)
+		if errors.Is(err, burrows.ErrUnknownBurrow) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-type", "application/json")
+		_ = json.NewEncoder(w).Encode(b)
+	}
+}
+
+// vacate lets gophers move out of a burrow.
+// The body is optional, without it all the occupants move out.
+func vacate(manager burrows.Manager) http.HandlerFunc {
+	type Request struct {
+		Gophers []string `json:"gophers"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		b, err := manager.Vacate(r.PathValue("name"), req.Gophers)
+		switch {
+		case errors.Is(err, burrows.ErrUnknownBurrow):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case errors.Is(err, burrows.ErrNotOccupant):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-type", "application/json")
+		_ = json.NewEncoder(w).Encode(b)
+	}
+}
+
+// exportContentTypes are the formats of the inventory export and their content types
+var exportContentTypes = map[burrows.Format]string{
+	burrows.FormatJSON:   "application/json",
+	burrows.FormatNDJSON: "application/x-ndjson",
+	burrows.FormatCSV:    "text/csv",
+	burrows.FormatText:   "text/plain; charset=utf-8",
+}
+
+// exportInventory writes all the burrows with their computed fields, like volume and remaining lifetime.
+// The `format` query parameter is one of json (default), ndjson, csv or text.
+// The optional `selector` query parameter restricts the export to the burrows with matching labels.
+func exportInventory(manager burrows.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		format := burrows.FormatJSON
+		if v := r.URL.Query().Get("format"); v != "" {
+			format = burrows.Format(v)
+		}
+		contentType, ok := exportContentTypes[format]
+		if !ok {
+			http.Error(w, "unknown export format: "+string(format), http.StatusBadRequest)
+			return
+		}
+		sel, err := burrows.ParseSelector(r.URL.Query().Get("selector"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		now, tact := time.Now(), manager.Speed().Tact
+		exp, err := burrows.NewExporter(w, format)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-type", contentType)
+		for _, b := range burrows.Filter(manager.CurrentStatus(), sel) {
+			if err := exp.Write(burrows.NewExportRecord(b, now, tact)); err != nil {
+				return
+			}
+		}
+		_ = exp.Flush()
+	}
+}
+
+// reportPreference is the order in which the report formats are offered to clients that accept several of them.
+// Formats registered later come last.
+var reportPreference = []string{"json", "csv", "text", "markdown", "html", "prometheus"}
+
+// showReport computes a fresh report. The format follows the `Accept` header, JSON by default,
+// and the `format` query parameter overrides it, ex: `format=prometheus`.
+// The optional `selector` and `site` query parameters restrict the report to the burrows with matching labels.
+func showReport(manager burrows.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sel, err := reportScope(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var enc burrows.ReportEncoder
+		if v := r.URL.Query().Get("format"); v != "" {
+			if enc, err = burrows.LookupReportEncoder(v); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		} else {
+			formats := slices.Clone(reportPreference)
+			for _, f := range burrows.ReportFormats() {
+				if !slices.Contains(formats, f) {
+					formats = append(formats, f)
+				}
+			}
+			var encoders []burrows.ReportEncoder
+			var contentTypes []string
+			for _, f := range formats {
+				if e, err := burrows.LookupReportEncoder(f); err == nil {
+					encoders = append(encoders, e)
+					contentTypes = append(contentTypes, e.ContentType())
+				}
+			}
+
+			w.Header().Set("Vary", "Accept")
+			i := negotiate(r.Header.Get("Accept"), contentTypes)
+			if i < 0 {
+				http.Error(w, "acceptable formats: "+strings.Join(contentTypes, ", "), http.StatusNotAcceptable)
+				return
+			}
+			enc = encoders[i]
+		}
+
+		w.Header().Set("Content-type", enc.ContentType())
+		_ = enc.Encode(w, manager.Report(sel))
+	}
+}
+
+// reportScope reads the selector of the burrows to report on. `site=north` is short for `selector=site=north`
+func reportScope(r *http.Request) (burrows.Selector, error) {
+	var requirements []string
+	if v := r.URL.Query().Get("selector"); v != "" {
+		requirements = append(requirements, v)
+	}
+	if site := r.URL.Query().Get("site"); site != "" {
+		if err := burrows.ValidateLabels(map[string]string{"site": site}); err != nil {
+			return burrows.Selector{}, err
+		}
+		requirements = append(requirements, "site="+site)
+	}
+	return burrows.ParseSelector(strings.Join(requirements, ","))
+}
+
+// maxReportPoints limits the points of a report series that is not downsampled explicitly
+const maxReportPoints = 1000
+
+// reportSeries is the time series of the reports taken from `From` until before `To`
+type reportSeries struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// Step is the duration averaged by every point, 0s if every report is a point
+	Step   string                `json:"step"`
+	Points []burrows.ReportPoint `json:"points"`
+}
+
+// showReportHistory returns the reports of the history as a time series.
+// `from` (default 24h before `to`) and `to` (default now) are RFC 3339 times or durations before now, ex: `from=7d`.
+// The series is downsampled to one point per `step`, ex: `step=1h`. Without a step, long ranges are downsampled
+// to at most `maxReportPoints` points.
+func showReportHistory(history burrows.ReportHistory, now func() time.Time) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if history == nil {
+			http.Error(w, "the report history is disabled", http.StatusNotFound)
+			return
+		}
+
+		t := now()
+		to, err := parseTimeParam(r.URL.Query().Get("to"), t, t)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		from, err := parseTimeParam(r.URL.Query().Get("from"), t, to.Add(-24*time.Hour))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !from.Before(to) {
+			http.Error(w, "from must be before to", http.StatusBadRequest)
+			return
+		}
+
+		step, explicit := time.Duration(0), false
+		if v := r.URL.Query().Get("step"); v != "" {
+			if step, err = burrows.ParseDuration(v); err != nil || step < 0 {
+				http.Error(w, "invalid step: "+v, http.StatusBadRequest)
+				return
+			}
+			explicit = true
+		}
+
+		reports, err := history.Range(from, to)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !explicit {
+			step = burrows.SeriesStep(from, to, len(reports), maxReportPoints)
+		}
+
+		series := reportSeries{From: from, To: to, Step: step.String(), Points: burrows.Downsample(reports, step)}
+		if series.Points == nil {
+			series.Points = []burrows.ReportPoint{}
+		}
+
+		w.Header().Set("Content-type", "application/json")
+		_ = json.NewEncoder(w).Encode(series)
+	}
+}
+
+// diffReports compares the burrows at two times, `from` (default 7d before `to`) and `to` (default now).
+// Both are RFC 3339 times or durations before now, ex: `from=7d&to=1d`. The burrows at a time are the ones of
+// the newest dump taken until then, or the newest report of the history if there is no dump. Without `to`
+// the current burrows are compared. Reports of the history are only compared by their metrics.
+// The format follows the `Accept` header, JSON by default, and the `format` query parameter (json or text) overrides it.
+// The optional `selector` and `site` query parameters restrict the comparison to the burrows with matching labels.
+func diffReports(manager burrows.Manager, dumps *burrows.Dumps, history burrows.ReportHistory, now func() time.Time) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sel, err := reportScope(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		t := now()
+		to, err := parseTimeParam(r.URL.Query().Get("to"), t, t)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		from, err := parseTimeParam(r.URL.Query().Get("from"), t, to.Add(-7*24*time.Hour))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !from.Before(to) {
+			http.Error(w, "from must be before to", http.StatusBadRequest)
+			return
+		}
+
+		contentTypes := []string{"application/json", "text/plain; charset=utf-8"}
+		i := 0
+		switch v := r.URL.Query().Get("format"); v {
+		case "", "json":
+			if v == "" {
+				w.Header().Set("Vary", "Accept")
+				if i = negotiate(r.Header.Get("Accept"), contentTypes); i < 0 {
+					http.Error(w, "acceptable formats: "+strings.Join(contentTypes, ", "), http.StatusNotAcceptable)
+					return
+				}
+			}
+		case "text":
+			i = 1
+		default:
+			http.Error(w, "unknown diff format: "+v, http.StatusBadRequest)
+			return
+		}
+
+		reportAt := func(at time.Time) (burrows.Report, int, error) {
+			if dumps != nil {
+				dump, err := dumps.At(at)
+				if err == nil {
+					snapshot, err := dump.Read()
+					if err != nil {
+						return burrows.Report{}, http.StatusInternalServerError, err
+					}
+					return burrows.NewSnapshotReport(snapshot, sel), 0, nil
+				}
+				if !errors.Is(err, burrows.ErrNoDump) {
+					return burrows.Report{}, http.StatusInternalServerError, err
+				}
+			}
+			if history == nil {
+				return burrows.Report{}, http.StatusNotFound, errors.New("no dump taken until " + at.Format(time.RFC3339))
+			}
+			if !sel.Empty() {
+				return burrows.Report{}, http.StatusBadRequest, errors.New("the reports of the history can not be restricted by labels")
+			}
+			reports, err := history.Range(time.Time{}, at.Add(time.Nanosecond))
+			if err != nil {
+				return burrows.Report{}, http.StatusInternalServerError, err
+			}
+			if len(reports) == 0 {
+				return burrows.Report{}, http.StatusNotFound, errors.New("no dump or report taken until " + at.Format(time.RFC3339))
+			}
+			return reports[len(reports)-1], 0, nil
+		}
+
+		before, status, err := reportAt(from)
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		var after burrows.Report
+		if r.URL.Query().Get("to") == "" {
+			after = manager.Report(sel)
+		} else if after, status, err = reportAt(to); err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		diff := burrows.DiffReports(before, after)
+		w.Header().Set("Content-type", contentTypes[i])
+		if i == 1 {
+			_ = diff.Write(w)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(diff)
+	}
+}
+
+// parseTimeParam reads an RFC 3339 time or a duration before now. An empty value is the default
+func parseTimeParam(v string, now, def time.Time) (time.Time, error) {
+	if v == "" {
+		return def, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	d, err := burrows.ParseDuration(v)
+	if err != nil {
+		return time.Time{}, errors.New("invalid time, expected RFC 3339 or a duration: " + v)
+	}
+	return now.Add(-d), nil
+}
+
+// showForecast predicts when the burrows collapse and how the availability evolves.
+// The `horizon` query parameter (default 7d) limits how far the forecast looks into the future
+// and the `step` parameter (default 1h for horizons up to 2 days, 1d otherwise) the resolution of the availability curve.
+func showForecast(manager burrows.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		horizon, step := 7*24*time.Hour, time.Duration(0)
+
+		var err error
+		if v := r.URL.Query().Get("horizon"); v != "" {
+			if horizon, err = burrows.ParseDuration(v); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if v := r.URL.Query().Get("step"); v != "" {
+			if step, err = burrows.ParseDuration(v); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		} else if horizon <= 48*time.Hour {
+			step = time.Hour
+		} else {
+			step = 24 * time.Hour
+		}
+
+		f, err := manager.Forecast(horizon, step)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-type", "application/json")
+		_ = json.NewEncoder(w).Encode(f)
+	}
+}
+
+// showSchedules lists the report schedules with their last and next run
+func showSchedules(schedules func() []burrows.ScheduleStatus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		statuses := []burrows.ScheduleStatus{}
+		if schedules != nil {
+			statuses = append(statuses, schedules()...)
+		}
+
+		w.Header().Set("Content-type", "application/json")
+		_ = json.NewEncoder(w).Encode(statuses)
+	}
+}
+
+// changeSpeed applies a change to the speed of all the burrows and responds with the new speed.
+// Failed changes are always caused by invalid parameters.
+func changeSpeed(change func(r *http.Request) (burrows.Speed, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		speed, err := change(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-type", "application/json")
+		_ = json.NewEncoder(w).Encode(speed)
+	}
+}
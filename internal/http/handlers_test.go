@@ -8,23 +8,27 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
 	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/mehix/gopher-burrows/internal/burrows"
 )
 
 var testData = []burrows.Burrow{
-	{Name: "Burrow 1"},
-	{Name: "Burrow 2"},
+	{Name: "Burrow 1", Capacity: 4, Labels: map[string]string{"site": "north"}},
+	{Name: "Burrow 2", Labels: map[string]string{"site": "south"}},
 }
 
 type manager struct {
 	data    []burrows.Burrow
 	canRent bool
+	speed   burrows.Speed
 }
 
 func (m *manager) CurrentStatus() []burrows.Burrow {
@@ -32,13 +36,67 @@ func (m *manager) CurrentStatus() []burrows.Burrow {
 }
 
 func (m *manager) Load(_ <-chan burrows.Burrow) {}
-func (m *manager) Rentout(_ context.Context) (burrows.Burrow, error) {
+func (m *manager) Rentout(_ context.Context, rental burrows.Rental) (burrows.Burrow, error) {
 	if m.canRent {
-		return m.data[0], nil
+		b := m.data[0]
+		b.Occupants = rental.Gophers
+		return b, nil
 	}
 	return burrows.Burrow{}, errors.New("no burrows available")
 }
-func (m *manager) Report() burrows.Report { return burrows.Report{} }
+func (m *manager) SetLabels(name string, labels map[string]string) (burrows.Burrow, error) {
+	for _, b := range m.data {
+		if b.Name == name {
+			b.Labels = labels
+			return b, nil
+		}
+	}
+	return burrows.Burrow{}, burrows.ErrUnknownBurrow
+}
+func (m *manager) Vacate(name string, gophers []string) (burrows.Burrow, error) {
+	for _, b := range m.data {
+		if b.Name == name {
+			if len(gophers) == 0 {
+				gophers = b.Occupants
+			}
+			if !b.MoveOut(gophers...) {
+				return b, burrows.ErrNotOccupant
+			}
+			return b, nil
+		}
+	}
+	return burrows.Burrow{}, burrows.ErrUnknownBurrow
+}
+func (m *manager) Reconcile(_ []burrows.Burrow) burrows.Reconciliation {
+	return burrows.Reconciliation{}
+}
+func (m *manager) Report(sel burrows.Selector) burrows.Report {
+	selected := burrows.Filter(m.data, sel)
+	r := burrows.NewReport(selected)
+	r.Burrows = selected
+	return r
+}
+func (m *manager) Forecast(horizon, step time.Duration) (burrows.Forecast, error) {
+	return burrows.NewForecast(m.data, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), burrows.Speed{Tact: time.Minute}, horizon, step)
+}
+
+func (m *manager) Speed() burrows.Speed { return m.speed }
+func (m *manager) Pause() burrows.Speed {
+	m.speed.Paused = true
+	return m.speed
+}
+func (m *manager) Resume() burrows.Speed {
+	m.speed.Paused = false
+	return m.speed
+}
+func (m *manager) SetTact(tact time.Duration) (burrows.Speed, error) {
+	if tact <= 0 {
+		return m.speed, errors.New("invalid tact")
+	}
+	m.speed.Tact = tact
+	return m.speed, nil
+}
+func (m *manager) Advance(d time.Duration) (burrows.Speed, error) { return m.speed, nil }
 
 var _ burrows.Manager = &manager{}
 
@@ -60,11 +118,199 @@ func TestShowStatus(t *testing.T) {
 		t.Error(err)
 	}
 
-	if !slices.Equal(testData, burrows) {
+	if !reflect.DeepEqual(testData, burrows) {
 		t.Errorf("received different data. expected: %v, got: %v", testData, burrows)
 	}
 }
 
+func TestShowStatusSelector(t *testing.T) {
+
+	m := &manager{data: testData}
+
+	srvr := httptest.NewServer(Handler(m))
+	defer srvr.Close()
+
+	resp, err := http.Get(srvr.URL + "/?selector=site%3Dsouth")
+	if err != nil {
+		t.Error(err)
+	}
+	defer resp.Body.Close()
+
+	var burrows []burrows.Burrow
+	if err := json.NewDecoder(resp.Body).Decode(&burrows); err != nil {
+		t.Error(err)
+	}
+
+	if !reflect.DeepEqual(testData[1:], burrows) {
+		t.Errorf("received different data. expected: %v, got: %v", testData[1:], burrows)
+	}
+}
+
+func TestSetLabels(t *testing.T) {
+
+	m := &manager{data: testData}
+
+	srvr := httptest.NewServer(Handler(m))
+	defer srvr.Close()
+
+	scenarios := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{name: "Burrow%201", body: `{"site": "west", "tier": "premium"}`, status: http.StatusOK},
+		{name: "Burrow%203", body: `{"site": "west"}`, status: http.StatusNotFound},
+		{name: "Burrow%201", body: `["site"]`, status: http.StatusBadRequest},
+	}
+
+	for _, s := range scenarios {
+		req, _ := http.NewRequest(http.MethodPut, srvr.URL+"/burrows/"+s.name+"/labels", strings.NewReader(s.body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != s.status {
+			t.Errorf("wrong status code for %s %s. expected: %d, got: %d", s.name, s.body, s.status, resp.StatusCode)
+		}
+	}
+}
+
+func TestVacate(t *testing.T) {
+
+	m := &manager{data: []burrows.Burrow{{Name: "Burrow 1", Capacity: 2, Occupants: []string{"Gus", "Goldie"}}}}
+
+	srvr := httptest.NewServer(Handler(m))
+	defer srvr.Close()
+
+	scenarios := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{name: "Burrow%201", body: `{"gophers": ["Gus"]}`, status: http.StatusOK},
+		{name: "Burrow%201", status: http.StatusOK},
+		{name: "Burrow%201", body: `{"gophers": ["Gordon"]}`, status: http.StatusConflict},
+		{name: "Burrow%203", status: http.StatusNotFound},
+		{name: "Burrow%201", body: `["Gus"]`, status: http.StatusBadRequest},
+	}
+
+	for _, s := range scenarios {
+		resp, err := http.Post(srvr.URL+"/burrows/"+s.name+"/vacate", "application/json", strings.NewReader(s.body))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode != s.status {
+			t.Errorf("wrong status code for %s %s. expected: %d, got: %d", s.name, s.body, s.status, resp.StatusCode)
+		}
+	}
+}
+
+func TestExport(t *testing.T) {
+
+	m := &manager{data: testData, speed: burrows.Speed{Tact: time.Minute}}
+
+	srvr := httptest.NewServer(Handler(m))
+	defer srvr.Close()
+
+	scenarios := []struct {
+		query       string
+		status      int
+		contentType string
+		lines       int
+	}{
+		{query: "", status: http.StatusOK, contentType: "application/json", lines: 4},
+		{query: "?format=ndjson", status: http.StatusOK, contentType: "application/x-ndjson", lines: 2},
+		{query: "?format=csv", status: http.StatusOK, contentType: "text/csv", lines: 3},
+		{query: "?format=text&selector=site%3Dnorth", status: http.StatusOK, contentType: "text/plain; charset=utf-8", lines: 2},
+		{query: "?format=yaml", status: http.StatusBadRequest},
+		{query: "?selector=site%3D%3D", status: http.StatusBadRequest},
+	}
+
+	for _, s := range scenarios {
+		resp, err := http.Get(srvr.URL + "/export" + s.query)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != s.status {
+			t.Errorf("wrong status code for %s. expected: %d, got: %d", s.query, s.status, resp.StatusCode)
+			continue
+		}
+		if s.status != http.StatusOK {
+			continue
+		}
+		if ct := resp.Header.Get("Content-type"); ct != s.contentType {
+			t.Errorf("wrong content type for %s. expected: %s, got: %s", s.query, s.contentType, ct)
+		}
+		if lines := strings.Count(string(body), "\n"); lines != s.lines {
+			t.Errorf("wrong number of lines for %s. expected: %d, got: %d\n%s", s.query, s.lines, lines, body)
+		}
+	}
+}
+
+func TestReport(t *testing.T) {
+
+	m := &manager{data: testData}
+
+	srvr := httptest.NewServer(Handler(m))
+	defer srvr.Close()
+
+	scenarios := []struct {
+		query       string
+		accept      string
+		status      int
+		contentType string
+		// body is a part of the expected body
+		body string
+	}{
+		{query: "", status: http.StatusOK, contentType: "application/json", body: `"freeSlots": 5`},
+		{query: "?site=north", accept: "text/csv", status: http.StatusOK, contentType: "text/csv", body: "4,"},
+		{query: "?selector=site%3Dsouth", accept: "text/plain", status: http.StatusOK, contentType: "text/plain; charset=utf-8", body: "FreeSlots\t1"},
+		{query: "", accept: "text/html;q=0.5, text/*;q=0.8, application/json;q=0.1", status: http.StatusOK, contentType: "text/csv"},
+		{query: "?format=prometheus", accept: "application/json", status: http.StatusOK, contentType: "text/plain; version=0.0.4; charset=utf-8", body: "burrows_free_slots 5"},
+		{query: "", accept: "image/png", status: http.StatusNotAcceptable},
+		{query: "?format=pdf", status: http.StatusBadRequest},
+		{query: "?site=north,south", status: http.StatusBadRequest},
+	}
+
+	for _, s := range scenarios {
+		req, _ := http.NewRequest(http.MethodGet, srvr.URL+"/report"+s.query, nil)
+		if s.accept != "" {
+			req.Header.Set("Accept", s.accept)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode != s.status {
+			t.Errorf("wrong status code for %s, accepting %s. expected: %d, got: %d", s.query, s.accept, s.status, resp.StatusCode)
+			continue
+		}
+		if s.status != http.StatusOK {
+			continue
+		}
+		if ct := resp.Header.Get("Content-type"); ct != s.contentType {
+			t.Errorf("wrong content type for %s, accepting %s. expected: %s, got: %s", s.query, s.accept, s.contentType, ct)
+		}
+		if !strings.Contains(string(body), s.body) {
+			t.Errorf("the report for %s should contain %q. got: %s", s.query, s.body, body)
+		}
+	}
+}
+
 func TestRentoutSuccess(t *testing.T) {
 
 	m := &manager{data: testData, canRent: true}
@@ -91,6 +337,50 @@ func TestRentoutSuccess(t *testing.T) {
 	}
 }
 
+func TestRentoutFamily(t *testing.T) {
+
+	m := &manager{data: testData, canRent: true}
+
+	srvr := httptest.NewServer(Handler(m))
+	defer srvr.Close()
+
+	resp, err := http.Post(srvr.URL+"/rent", "application/json", strings.NewReader(`{"gophers": ["mum", "dad", "kid"]}`))
+	if err != nil {
+		t.Error(err)
+	}
+	defer resp.Body.Close()
+
+	var response = struct {
+		Burrow burrows.Burrow
+		Error  string
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Error(err)
+	}
+
+	if len(response.Burrow.Occupants) != 3 {
+		t.Errorf("the whole family should move in. received: %v", response)
+	}
+}
+
+func TestRentoutBadRequest(t *testing.T) {
+
+	m := &manager{data: testData, canRent: true}
+
+	srvr := httptest.NewServer(Handler(m))
+	defer srvr.Close()
+
+	resp, err := http.Post(srvr.URL+"/rent", "application/json", strings.NewReader(`{"gophers": `))
+	if err != nil {
+		t.Error(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("wrong status code. expected: %d, got: %d", http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
 func TestRentoutFail(t *testing.T) {
 
 	m := &manager{data: testData, canRent: false}
@@ -120,3 +410,279 @@ func TestRentoutFail(t *testing.T) {
 		t.Errorf("in case of error the Burrow should be empty. received: %v", response)
 	}
 }
+
+func TestShowForecast(t *testing.T) {
+
+	m := &manager{data: testData}
+
+	srvr := httptest.NewServer(Handler(m))
+	defer srvr.Close()
+
+	scenarios := []struct {
+		query  string
+		status int
+		points int
+	}{
+		{query: "", status: http.StatusOK, points: 8},
+		{query: "?horizon=2d", status: http.StatusOK, points: 49},
+		{query: "?horizon=3d&step=12h", status: http.StatusOK, points: 7},
+		{query: "?horizon=tomorrow", status: http.StatusBadRequest},
+		{query: "?horizon=1d&step=0s", status: http.StatusBadRequest},
+	}
+
+	for _, s := range scenarios {
+		resp, err := http.Get(srvr.URL + "/forecast" + s.query)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if resp.StatusCode != s.status {
+			t.Errorf("wrong status code for %q. expected: %d, got: %d", s.query, s.status, resp.StatusCode)
+		}
+
+		if s.status == http.StatusOK {
+			var f burrows.Forecast
+			if err := json.NewDecoder(resp.Body).Decode(&f); err != nil {
+				t.Error(err)
+			}
+			if len(f.Burrows) != len(testData) || len(f.Availability) != s.points {
+				t.Errorf("wrong forecast for %q. expected %d burrows and %d points, got: %+v", s.query, len(testData), s.points, f)
+			}
+		}
+		resp.Body.Close()
+	}
+}
+
+func TestChangeSpeed(t *testing.T) {
+
+	m := &manager{data: testData, speed: burrows.Speed{Tact: time.Minute}}
+
+	srvr := httptest.NewServer(Handler(m))
+	defer srvr.Close()
+
+	scenarios := []struct {
+		method, path string
+		status       int
+		speed        burrows.Speed
+	}{
+		{method: http.MethodGet, path: "/admin/speed", status: http.StatusOK, speed: burrows.Speed{Tact: time.Minute}},
+		{method: http.MethodPost, path: "/admin/speed/pause", status: http.StatusOK, speed: burrows.Speed{Tact: time.Minute, Paused: true}},
+		{method: http.MethodPost, path: "/admin/speed/tact?value=10ms", status: http.StatusOK, speed: burrows.Speed{Tact: 10 * time.Millisecond, Paused: true}},
+		{method: http.MethodPost, path: "/admin/speed/tact?value=fast", status: http.StatusBadRequest},
+		{method: http.MethodPost, path: "/admin/speed/tact?value=-1s", status: http.StatusBadRequest},
+		{method: http.MethodPost, path: "/admin/speed/advance?by=2d", status: http.StatusOK, speed: burrows.Speed{Tact: 10 * time.Millisecond, Paused: true}},
+		{method: http.MethodPost, path: "/admin/speed/resume", status: http.StatusOK, speed: burrows.Speed{Tact: 10 * time.Millisecond}},
+	}
+
+	for _, s := range scenarios {
+		req, _ := http.NewRequest(s.method, srvr.URL+s.path, nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if resp.StatusCode != s.status {
+			t.Errorf("wrong status code for %s. expected: %d, got: %d", s.path, s.status, resp.StatusCode)
+		}
+
+		if s.status == http.StatusOK {
+			var speed burrows.Speed
+			if err := json.NewDecoder(resp.Body).Decode(&speed); err != nil {
+				t.Error(err)
+			}
+			if speed != s.speed {
+				t.Errorf("wrong speed after %s. expected: %+v, got: %+v", s.path, s.speed, speed)
+			}
+		}
+		resp.Body.Close()
+	}
+}
+
+type reportHistory []burrows.Report
+
+func (h reportHistory) Append(_ burrows.Report) error { return nil }
+
+func (h reportHistory) Range(from, to time.Time) ([]burrows.Report, error) {
+	var reports []burrows.Report
+	for _, r := range h {
+		if !r.Taken.Before(from) && r.Taken.Before(to) {
+			reports = append(reports, r)
+		}
+	}
+	return reports, nil
+}
+
+func (h reportHistory) Close() error { return nil }
+
+func TestReportHistory(t *testing.T) {
+
+	now := time.Date(2024, 3, 2, 10, 0, 0, 0, time.UTC)
+	var history reportHistory
+	for i := 2 * 24 * 60; i > 0; i-- {
+		history = append(history, burrows.Report{Taken: now.Add(-time.Duration(i) * time.Minute), TotalDepth: 1})
+	}
+	handler := showReportHistory(history, func() time.Time { return now })
+
+	scenarios := []struct {
+		query  string
+		status int
+		step   string
+		points int
+	}{
+		// a report every minute for a day is downsampled
+		{query: "", status: http.StatusOK, step: "2m0s", points: 720},
+		{query: "?from=2024-03-01T10:00:00Z&to=2024-03-01T12:00:00Z&step=1h", status: http.StatusOK, step: "1h0m0s", points: 2},
+		{query: "?from=6h&to=2h", status: http.StatusOK, step: "0s", points: 240},
+		{query: "?from=2h&step=0", status: http.StatusOK, step: "0s", points: 120},
+		{query: "?from=yesterday", status: http.StatusBadRequest},
+		{query: "?from=1h&to=2h", status: http.StatusBadRequest},
+		{query: "?step=-1h", status: http.StatusBadRequest},
+	}
+
+	for _, s := range scenarios {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, "/reports"+s.query, nil))
+
+		if rec.Code != s.status {
+			t.Errorf("wrong status code for %q. expected: %d, got: %d", s.query, s.status, rec.Code)
+			continue
+		}
+		if s.status != http.StatusOK {
+			continue
+		}
+		var series reportSeries
+		if err := json.NewDecoder(rec.Body).Decode(&series); err != nil {
+			t.Fatal(err)
+		}
+		if series.Step != s.step || len(series.Points) != s.points {
+			t.Errorf("wrong series for %q. expected %d points every %s, got: %d every %s", s.query, s.points, s.step, len(series.Points), series.Step)
+		}
+	}
+
+	srvr := httptest.NewServer(Handler(&manager{data: testData}))
+	defer srvr.Close()
+	resp, err := http.Get(srvr.URL + "/reports")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("without a history the series should not be found. got: %d", resp.StatusCode)
+	}
+}
+
+func TestSchedules(t *testing.T) {
+
+	cron, _ := burrows.ParseCron("@hourly")
+	next := time.Date(2024, 3, 1, 11, 0, 0, 0, time.UTC)
+	schedules := func() []burrows.ScheduleStatus {
+		return []burrows.ScheduleStatus{{
+			ReportSchedule: burrows.ReportSchedule{Name: "hourly", Cron: cron, Formats: []string{"json"}, Dir: "/tmp"},
+			NextRun:        &next,
+		}}
+	}
+
+	srvr := httptest.NewServer(Handler(&manager{data: testData}, WithSchedules(schedules)))
+	defer srvr.Close()
+
+	resp, err := http.Get(srvr.URL + "/admin/schedules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var listed []map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&listed); err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 1 || listed[0]["name"] != "hourly" || listed[0]["cron"] != "@hourly" || listed[0]["nextRun"] != "2024-03-01T11:00:00Z" {
+		t.Errorf("wrong schedules. got: %v", listed)
+	}
+	if _, ok := listed[0]["lastRun"]; ok {
+		t.Errorf("a schedule that never ran should have no last run. got: %v", listed[0])
+	}
+}
+
+func TestDiffReports(t *testing.T) {
+
+	now := time.Date(2024, 3, 8, 10, 0, 0, 0, time.UTC)
+	dumps := burrows.Dumps{Dir: t.TempDir()}
+	for _, s := range []burrows.Snapshot{
+		{Taken: now.Add(-7 * 24 * time.Hour), Burrows: []burrows.Burrow{
+			{Name: "Burrow 0", Occupants: []string{"Alice"}},
+			{Name: "Burrow 1", Capacity: 4, Labels: map[string]string{"site": "north"}},
+		}},
+		{Taken: now.Add(-24 * time.Hour), Burrows: testData},
+	} {
+		if _, err := dumps.Write(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	history := reportHistory{{Taken: now.Add(-30 * 24 * time.Hour), TotalDepth: 1, Count: 1}}
+	m := &manager{data: []burrows.Burrow{testData[0], {Name: "Burrow 2", Occupants: []string{"Bob"}, Labels: map[string]string{"site": "south"}}}}
+	handler := diffReports(m, &dumps, history, func() time.Time { return now })
+
+	scenarios := []struct {
+		query    string
+		status   int
+		detailed bool
+		added    []string
+		removed  []string
+		occupied []string
+		vacated  []string
+	}{
+		// the dump of a week ago with the current burrows
+		{query: "", status: http.StatusOK, detailed: true, added: []string{"Burrow 2"}, removed: []string{"Burrow 0"}, occupied: []string{}, vacated: []string{}},
+		{query: "?from=7d&to=1d", status: http.StatusOK, detailed: true, added: []string{"Burrow 2"}, removed: []string{"Burrow 0"}, occupied: []string{}, vacated: []string{}},
+		{query: "?from=1d", status: http.StatusOK, detailed: true, added: []string{}, removed: []string{}, occupied: []string{"Burrow 2"}, vacated: []string{}},
+		{query: "?from=1d&site=north", status: http.StatusOK, detailed: true, added: []string{}, removed: []string{}, occupied: []string{}, vacated: []string{}},
+		// older than the dumps, the report of the history only has metrics
+		{query: "?from=30d&to=1d", status: http.StatusOK, added: []string{}, removed: []string{}, occupied: []string{}, vacated: []string{}},
+		{query: "?from=30d&site=north", status: http.StatusBadRequest},
+		{query: "?from=60d", status: http.StatusNotFound},
+		{query: "?from=1d&to=7d", status: http.StatusBadRequest},
+		{query: "?format=xml", status: http.StatusBadRequest},
+	}
+
+	for _, s := range scenarios {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, "/reports/diff"+s.query, nil))
+
+		if rec.Code != s.status {
+			t.Errorf("wrong status code for %q. expected: %d, got: %d %s", s.query, s.status, rec.Code, rec.Body)
+			continue
+		}
+		if s.status != http.StatusOK {
+			continue
+		}
+		var diff burrows.ReportDiff
+		if err := json.NewDecoder(rec.Body).Decode(&diff); err != nil {
+			t.Fatal(err)
+		}
+		if diff.Detailed != s.detailed || !slices.Equal(diff.Added, s.added) || !slices.Equal(diff.Removed, s.removed) ||
+			!slices.Equal(diff.Occupied, s.occupied) || !slices.Equal(diff.Vacated, s.vacated) {
+			t.Errorf("wrong diff for %q. got: %+v", s.query, diff)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/reports/diff?from=7d&to=1d", nil)
+	req.Header.Set("Accept", "text/plain")
+	handler(rec, req)
+	if ct := rec.Header().Get("Content-type"); !strings.HasPrefix(ct, "text/plain") || !strings.Contains(rec.Body.String(), "Removed    1  Burrow 0") {
+		t.Errorf("the diff should be printed as text. got: %s\n%s", ct, rec.Body)
+	}
+
+	srvr := httptest.NewServer(Handler(m))
+	defer srvr.Close()
+	resp, err := http.Get(srvr.URL + "/reports/diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("without dumps and history there is nothing to compare. got: %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,76 @@
+package http
+
+import (
+	"mime"
+	"strconv"
+	"strings"
+)
+
+// acceptRange is one media range of an `Accept` header, ex: `text/*;q=0.5`
+type acceptRange struct {
+	typ, subtype string
+	q            float64
+}
+
+// parseAccept returns the media ranges of an `Accept` header. Invalid ranges are ignored.
+func parseAccept(header string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(header, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		typ, subtype, ok := strings.Cut(mediaType, "/")
+		if !ok {
+			continue
+		}
+		r := acceptRange{typ: typ, subtype: subtype, q: 1}
+		if v, ok := params["q"]; ok {
+			if q, err := strconv.ParseFloat(v, 64); err == nil && q >= 0 && q <= 1 {
+				r.q = q
+			}
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges
+}
+
+// negotiate picks the content type that the client prefers, according to the `Accept` header.
+// Among content types with the same preference the first one wins, it is also the one used without header.
+// It returns -1 if the client accepts none of them.
+func negotiate(header string, contentTypes []string) int {
+	if strings.TrimSpace(header) == "" {
+		return 0
+	}
+	ranges := parseAccept(header)
+
+	best, bestQ := -1, 0.0
+	for i, ct := range contentTypes {
+		mediaType, _, err := mime.ParseMediaType(ct)
+		if err != nil {
+			continue
+		}
+		typ, subtype, _ := strings.Cut(mediaType, "/")
+
+		// the most specific range decides
+		q, specificity := 0.0, -1
+		for _, r := range ranges {
+			s := -1
+			switch {
+			case r.typ == typ && r.subtype == subtype:
+				s = 2
+			case r.typ == typ && r.subtype == "*":
+				s = 1
+			case r.typ == "*" && r.subtype == "*":
+				s = 0
+			}
+			if s > specificity {
+				q, specificity = r.q, s
+			}
+		}
+		if q > bestQ {
+			best, bestQ = i, q
+		}
+	}
+	return best
+}
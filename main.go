@@ -54,7 +54,7 @@ func Run() {
 				time.Sleep(time.Duration(rand.Int63n(5)) * time.Second)
 				t, cancel := context.WithTimeout(ctx, 2*time.Second)
 				defer cancel()
-				b, err := manager.Rentout(t)
+				b, err := manager.Rentout(t, burrows.Rental{})
 				if err != nil {
 					log.Println("rentingout", err)
 				} else {
@@ -76,7 +76,7 @@ func Run() {
 				fmt.Println(b)
 			}
 		case <-repoTkr.C:
-			rep := manager.Report()
+			rep := manager.Report(burrows.Selector{})
 			b, _ := json.MarshalIndent(rep, "  ", "  ")
 			io.Copy(os.Stdout, bytes.NewReader(b))
 			fmt.Println()